@@ -57,51 +57,124 @@ type Config struct {
 	}
 
 	Event struct {
+		Driver string `mapstructure:"DRIVER"`
+
+		File struct {
+			Directory                string `mapstructure:"DIRECTORY"`
+			PollIntervalMilliseconds int64  `mapstructure:"POLL_INTERVAL_MILLISECONDS"`
+		}
+
 		Consumer struct {
+			Idempotency struct {
+				Driver       string `mapstructure:"DRIVER"`
+				LeaseSeconds int64  `mapstructure:"LEASE_SECONDS"`
+				TTLSeconds   int64  `mapstructure:"TTL_SECONDS"`
+			}
+
+			Push struct {
+				Enabled bool `mapstructure:"ENABLED"`
+
+				Topics struct {
+					FooBarBaz struct {
+						ARN string `mapstructure:"ARN"`
+					} `mapstructure:"FOOBARBAZ"`
+				}
+			}
+
+			Signature struct {
+				AllowedHosts []string `mapstructure:"ALLOWED_HOSTS"`
+				Enabled      bool     `mapstructure:"ENABLED"`
+			}
+
 			SQS struct {
-				AccessKeyID       string `mapstructure:"ACCESS_KEY_ID"`
-				BackoffSeconds    int    `mapstructure:"BACKOFF_SECONDS"`
-				MaxMessage        int64  `mapstructure:"MAX_MESSAGE"`
-				MaxRetries        int    `mapstructure:"MAX_RETRIES"`
-				MaxRetriesConsume int    `mapstructure:"MAX_RETRIES_CONSUME"`
-				Region            string `mapstructure:"REGION"`
-				SecretAccessKey   string `mapstructure:"SECRET_ACCESS_KEY"`
-				WaitTimeSeconds   int64  `mapstructure:"WAIT_TIME_SECONDS"`
+				AccessKeyID              string `mapstructure:"ACCESS_KEY_ID"`
+				BackoffSeconds           int    `mapstructure:"BACKOFF_SECONDS"`
+				DisableSSL               bool   `mapstructure:"DISABLE_SSL"`
+				Endpoint                 string `mapstructure:"ENDPOINT"`
+				ForcePathStyle           bool   `mapstructure:"FORCE_PATH_STYLE"`
+				MaxMessage               int64  `mapstructure:"MAX_MESSAGE"`
+				MaxReceiveCount          int    `mapstructure:"MAX_RECEIVE_COUNT"`
+				MaxRetries               int    `mapstructure:"MAX_RETRIES"`
+				MaxRetriesConsume        int    `mapstructure:"MAX_RETRIES_CONSUME"`
+				Region                   string `mapstructure:"REGION"`
+				SecretAccessKey          string `mapstructure:"SECRET_ACCESS_KEY"`
+				VisibilityBackoffSeconds int64  `mapstructure:"VISIBILITY_BACKOFF_SECONDS"`
+				VisibilityTimeoutSeconds int64  `mapstructure:"VISIBILITY_TIMEOUT_SECONDS"`
+				WaitTimeSeconds          int64  `mapstructure:"WAIT_TIME_SECONDS"`
+				Workers                  int    `mapstructure:"WORKERS"`
 
 				Topics struct {
 					FooBarBaz struct {
-						Enabled bool   `mapstructure:"ENABLED"`
-						URL     string `mapstructure:"URL"`
+						DeadLetterURL string `mapstructure:"DEAD_LETTER_URL"`
+						Enabled       bool   `mapstructure:"ENABLED"`
+						URL           string `mapstructure:"URL"`
 					} `mapstructure:"FOOBARBAZ"`
 				}
 			}
 		}
 
+		Outbox struct {
+			BatchSize            int   `mapstructure:"BATCH_SIZE"`
+			Enabled              bool  `mapstructure:"ENABLED"`
+			IntervalMilliseconds int64 `mapstructure:"INTERVAL_MILLISECONDS"`
+			MaxAttempts          int   `mapstructure:"MAX_ATTEMPTS"`
+		}
+
 		Producer struct {
 			SNS struct {
 				AccessKeyID     string `mapstructure:"ACCESS_KEY_ID"`
+				DisableSSL      bool   `mapstructure:"DISABLE_SSL"`
+				Endpoint        string `mapstructure:"ENDPOINT"`
+				ForcePathStyle  bool   `mapstructure:"FORCE_PATH_STYLE"`
 				MaxRetries      int    `mapstructure:"MAX_RETRIES"`
 				Region          string `mapstructure:"REGION"`
 				SecretAccessKey string `mapstructure:"SECRET_ACCESS_KEY"`
+				TimeoutSeconds  int64  `mapstructure:"TIMEOUT_SECONDS"`
 				Topics          struct {
+					AuditEvent struct {
+						ARN     string `mapstructure:"ARN"`
+						Enabled bool   `mapstructure:"ENABLED"`
+					} `mapstructure:"AUDIT_EVENT"`
 					FooCreated struct {
 						ARN     string `mapstructure:"ARN"`
 						Enabled bool   `mapstructure:"ENABLED"`
 					} `mapstructure:"FOO_CREATED"`
+					UserLifecycle struct {
+						ARN     string `mapstructure:"ARN"`
+						Enabled bool   `mapstructure:"ENABLED"`
+					} `mapstructure:"USER_LIFECYCLE"`
 				}
 			}
 		}
 	}
 
+	Janitor struct {
+		BatchSize       int   `mapstructure:"BATCH_SIZE"`
+		Enabled         bool  `mapstructure:"ENABLED"`
+		IntervalSeconds int64 `mapstructure:"INTERVAL_SECONDS"`
+	}
+
 	Server struct {
-		Env      string `mapstructure:"ENV"`
-		LogLevel string `mapstructure:"LOG_LEVEL"`
-		Port     string `mapstructure:"PORT"`
-		Shutdown struct {
+		Env          string `mapstructure:"ENV"`
+		InternalPort string `mapstructure:"INTERNAL_PORT"`
+		LogFormat    string `mapstructure:"LOG_FORMAT"`
+		LogLevel     string `mapstructure:"LOG_LEVEL"`
+		Port         string `mapstructure:"PORT"`
+		Shutdown     struct {
 			CleanupPeriodSeconds int64 `mapstructure:"CLEANUP_PERIOD_SECONDS"`
 			GracePeriodSeconds   int64 `mapstructure:"GRACE_PERIOD_SECONDS"`
 		}
 	}
+
+	Tracing struct {
+		Enabled  bool   `mapstructure:"ENABLED"`
+		Exporter string `mapstructure:"EXPORTER"`
+		OTLP     struct {
+			Endpoint string `mapstructure:"ENDPOINT"`
+			Insecure bool   `mapstructure:"INSECURE"`
+		}
+		SampleRatio float64 `mapstructure:"SAMPLE_RATIO"`
+	}
 }
 
 var (
@@ -0,0 +1,45 @@
+// Package broker provides local stand-ins for SNS and SQS, so the event path
+// can run without AWS during development and in integration tests. Producers
+// publish to a name, usually the topic ARN, and consumers listen on the same
+// name in place of a queue URL.
+package broker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// DriverAWS selects SNS and SQS.
+	DriverAWS = "aws"
+	// DriverMemory selects the in-process broker.
+	DriverMemory = "memory"
+	// DriverFile selects the directory-spooled broker.
+	DriverFile = "file"
+)
+
+// Wrap wraps a message and its string attributes in the SNS envelope that
+// consumers expect from SQS.
+func Wrap(topic string, message []byte, attributes map[string]string) ([]byte, error) {
+	id, _ := uuid.NewV4()
+
+	envelope := model.SNSMessage{
+		Type:      "Notification",
+		MessageID: id,
+		TopicARN:  topic,
+		Message:   string(message),
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	if len(attributes) > 0 {
+		envelope.MessageAttributes = make(map[string]model.SNSMessageAttribute, len(attributes))
+		for name, value := range attributes {
+			envelope.MessageAttributes[name] = model.SNSMessageAttribute{Type: "String", Value: value}
+		}
+	}
+
+	return json.Marshal(envelope)
+}
@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	fileExtension = ".json"
+	failedDir     = "failed"
+)
+
+var unsafeNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// File is a broker that spools messages as files in a directory, one
+// subdirectory per name, so that separate processes can exchange messages.
+// Messages are consumed in the order they were published.
+//
+// Consumers do not lock the messages they read, so run a single consumer per
+// name; several consumers of the same directory process messages twice.
+type File struct {
+	Directory string
+}
+
+// Publish writes a message to a name's directory.
+func (f *File) Publish(name string, body []byte) (err error) {
+	dir := f.dir(name)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	id, _ := uuid.NewV4()
+	file := fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), id, fileExtension)
+
+	// write to a hidden file first so consumers never read a partial message
+	tmp := filepath.Join(dir, "."+file)
+	if err = ioutil.WriteFile(tmp, body, 0644); err != nil {
+		return
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, file))
+}
+
+// Pending lists the paths of a name's messages, oldest first.
+func (f *File) Pending(name string) (paths []string, err error) {
+	paths, err = filepath.Glob(filepath.Join(f.dir(name), "[0-9]*"+fileExtension))
+	sort.Strings(paths)
+	return
+}
+
+// Read reads a message.
+func (f *File) Read(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+// Ack removes a processed message.
+func (f *File) Ack(path string) error {
+	return os.Remove(path)
+}
+
+// Reject moves a message that cannot be processed to the name's failed
+// subdirectory, where it is kept for inspection.
+func (f *File) Reject(path string) (err error) {
+	dir := filepath.Join(filepath.Dir(path), failedDir)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+
+	return os.Rename(path, filepath.Join(dir, filepath.Base(path)))
+}
+
+func (f *File) dir(name string) string {
+	return filepath.Join(f.Directory, unsafeNameCharacters.ReplaceAllString(name, "_"))
+}
@@ -0,0 +1,142 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"sync"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	memoryTopic         = "broker"
+	memoryMessageBuffer = 100
+	// memoryListenerBuffer is how many messages a listener may fall behind
+	// before messages to it are dropped.
+	memoryListenerBuffer = 100
+)
+
+var (
+	defaultMemory     *Memory
+	defaultMemoryOnce sync.Once
+)
+
+// Memory is an in-process broker backed by shared.PubSub. Every listener on a
+// name receives each message published to it while it is subscribed; messages
+// published to a name nobody listens on are dropped. Each listener has its own
+// buffer, so a slow listener never holds up the others: once its buffer is
+// full, further messages to it are dropped.
+type Memory struct {
+	pubsub    *shared.PubSub
+	mu        sync.RWMutex
+	listeners map[string][]*memoryListener
+}
+
+type memoryListener struct {
+	deliveries chan []byte
+	done       chan struct{}
+}
+
+type memoryEnvelope struct {
+	Name string `json:"name"`
+	Body []byte `json:"body"`
+}
+
+// DefaultMemory returns the broker shared by every producer and consumer in
+// this process. Its stats are published under broker.memory at /debug/vars.
+func DefaultMemory() *Memory {
+	defaultMemoryOnce.Do(func() {
+		defaultMemory = NewMemory()
+		expvar.Publish("broker.memory", expvar.Func(func() interface{} {
+			return defaultMemory.Stats()
+		}))
+	})
+
+	return defaultMemory
+}
+
+// NewMemory creates and starts a new in-process broker.
+func NewMemory() *Memory {
+	m := &Memory{
+		pubsub:    shared.New(1, shared.SetMessageBuffer(memoryMessageBuffer)),
+		listeners: make(map[string][]*memoryListener),
+	}
+	m.pubsub.SubscriberRegistry(memoryTopic, m.deliver)
+	m.pubsub.Start()
+
+	return m
+}
+
+// Publish publishes a message to every listener on a name.
+func (m *Memory) Publish(name string, body []byte) error {
+	payload, err := json.Marshal(memoryEnvelope{Name: name, Body: body})
+	if err != nil {
+		return err
+	}
+
+	return m.pubsub.Publish(memoryTopic, payload)
+}
+
+// Stats returns a snapshot of the queue of this broker.
+func (m *Memory) Stats() shared.Stats {
+	return m.pubsub.Stats()
+}
+
+// Subscribe starts listening on a name. Deliveries stop once unsubscribe is
+// called.
+func (m *Memory) Subscribe(name string) (deliveries <-chan []byte, unsubscribe func()) {
+	listener := &memoryListener{
+		deliveries: make(chan []byte, memoryListenerBuffer),
+		done:       make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.listeners[name] = append(m.listeners[name], listener)
+	m.mu.Unlock()
+
+	var once sync.Once
+	return listener.deliveries, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			listeners := m.listeners[name]
+			for i, l := range listeners {
+				if l == listener {
+					m.listeners[name] = append(listeners[:i:i], listeners[i+1:]...)
+					break
+				}
+			}
+			close(listener.done)
+		})
+	}
+}
+
+func (m *Memory) deliver(_ context.Context, payload []byte) error {
+	envelope := memoryEnvelope{}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	listeners := append([]*memoryListener(nil), m.listeners[envelope.Name]...)
+	m.mu.RUnlock()
+
+	if len(listeners) == 0 {
+		log.Warn().Str("name", envelope.Name).Msg("No listener for message, dropping it.")
+		return nil
+	}
+
+	for _, listener := range listeners {
+		select {
+		case listener.deliveries <- envelope.Body:
+		case <-listener.done:
+		default:
+			log.Warn().Str("name", envelope.Name).Msg("Listener is falling behind, dropping message.")
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package event
 
 import (
+	"github.com/evermos/boilerplate-go/event/consumer"
 	"github.com/evermos/boilerplate-go/event/domain/foobarbaz"
 )
 
@@ -20,3 +21,39 @@ func ProvideConsumers(fooBarBaz foobarbaz.ConsumerImpl) Consumers {
 func (c *Consumers) Start() {
 	c.FooBarBaz.Start()
 }
+
+// Stop stops all domains event consumer, waiting for the messages in flight
+// to be processed.
+func (c *Consumers) Stop() {
+	c.FooBarBaz.Stop()
+}
+
+// Subscription returns how to process the messages pushed from an SNS topic,
+// if a consumer subscribes to it.
+func (c *Consumers) Subscription(topicARN string) (process consumer.Process, ok bool) {
+	if topicARN == "" {
+		return nil, false
+	}
+
+	if topicARN == c.FooBarBaz.Subscription() {
+		return c.FooBarBaz.Process, true
+	}
+
+	return nil, false
+}
+
+// Health returns the state of every consumer by name, and whether all of them
+// are healthy.
+func (c *Consumers) Health() (interface{}, bool) {
+	health := map[string]consumer.Health{
+		"fooBarBaz": c.FooBarBaz.Health(),
+	}
+
+	for _, h := range health {
+		if !h.IsHealthy() {
+			return health, false
+		}
+	}
+
+	return health, true
+}
@@ -0,0 +1,387 @@
+package consumer_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAWS is a stand-in for SNS and SQS speaking the query protocol. Every
+// published message is delivered to a single queue, wrapped in an SNS
+// envelope like a real subscription does. Received messages stay in flight
+// until they are deleted or made visible again.
+type fakeAWS struct {
+	mu        sync.Mutex
+	sequence  int
+	queue     []fakeMessage
+	inFlight  map[string]fakeMessage
+	deleted   []string
+	published []fakePublished
+	arrived   chan struct{}
+}
+
+// fakePublished records what a message was published with.
+type fakePublished struct {
+	topic           string
+	attributes      map[string]string
+	deduplicationID string
+}
+
+type fakeMessage struct {
+	id           string
+	body         string
+	receiveCount int
+}
+
+func newFakeAWS() *fakeAWS {
+	return &fakeAWS{
+		inFlight: make(map[string]fakeMessage),
+		arrived:  make(chan struct{}, 1),
+	}
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		f.fail(w, "MalformedQueryString", err.Error())
+		return
+	}
+
+	switch action := r.Form.Get("Action"); action {
+	case "Publish":
+		f.publish(w, r)
+	case "PublishBatch":
+		f.publishBatch(w, r)
+	case "ReceiveMessage":
+		f.receiveMessage(w, r)
+	case "DeleteMessage":
+		f.deleteMessage(w, r)
+	case "DeleteMessageBatch":
+		f.deleteMessageBatch(w, r)
+	case "ChangeMessageVisibility":
+		f.changeMessageVisibility(w, r)
+	default:
+		f.fail(w, "InvalidAction", "unsupported action "+action)
+	}
+}
+
+func (f *fakeAWS) publish(w http.ResponseWriter, r *http.Request) {
+	id, err := f.enqueue(r.Form, r.Form.Get("TopicArn"), "")
+	if err != nil {
+		f.fail(w, "InternalError", err.Error())
+		return
+	}
+
+	f.respond(w, "Publish", "<PublishResult><MessageId>"+id+"</MessageId></PublishResult>")
+}
+
+// publishBatch rejects entries whose message is "reject".
+func (f *fakeAWS) publishBatch(w http.ResponseWriter, r *http.Request) {
+	var successful, failed strings.Builder
+	for i := 1; ; i++ {
+		prefix := "PublishBatchRequestEntries.member." + strconv.Itoa(i) + "."
+		entryID := r.Form.Get(prefix + "Id")
+		if entryID == "" {
+			break
+		}
+
+		event, _, _ := model.DecodeCloudEvent([]byte(r.Form.Get(prefix + "Message")))
+		if string(event.Data) == `"reject"` {
+			failed.WriteString("<member><Id>" + escape(entryID) + "</Id><Code>InvalidParameter</Code>" +
+				"<Message>rejected</Message><SenderFault>true</SenderFault></member>")
+			continue
+		}
+
+		id, err := f.enqueue(r.Form, r.Form.Get("TopicArn"), prefix)
+		if err != nil {
+			f.fail(w, "InternalError", err.Error())
+			return
+		}
+		successful.WriteString("<member><Id>" + escape(entryID) + "</Id><MessageId>" + id + "</MessageId></member>")
+	}
+
+	f.respond(w, "PublishBatch", "<PublishBatchResult><Successful>"+successful.String()+"</Successful>"+
+		"<Failed>"+failed.String()+"</Failed></PublishBatchResult>")
+}
+
+// enqueue delivers the message whose parameters start with prefix to the
+// queue, and returns its ID.
+func (f *fakeAWS) enqueue(form url.Values, topic string, prefix string) (id string, err error) {
+	attributes := make(map[string]string)
+	for i := 1; ; i++ {
+		entry := prefix + "MessageAttributes.entry." + strconv.Itoa(i) + "."
+		name := form.Get(entry + "Name")
+		if name == "" {
+			break
+		}
+		attributes[name] = form.Get(entry + "Value.StringValue")
+	}
+
+	body, err := broker.Wrap(topic, []byte(form.Get(prefix+"Message")), attributes)
+	if err != nil {
+		return
+	}
+
+	f.mu.Lock()
+	f.sequence++
+	id = strconv.Itoa(f.sequence)
+	f.queue = append(f.queue, fakeMessage{id: id, body: string(body)})
+	f.published = append(f.published, fakePublished{
+		topic:           topic,
+		attributes:      attributes,
+		deduplicationID: form.Get(prefix + "MessageDeduplicationId"),
+	})
+	f.mu.Unlock()
+
+	select {
+	case f.arrived <- struct{}{}:
+	default:
+	}
+
+	return
+}
+
+// receiveMessage long polls for up to WaitTimeSeconds, like SQS does.
+func (f *fakeAWS) receiveMessage(w http.ResponseWriter, r *http.Request) {
+	max, _ := strconv.Atoi(r.Form.Get("MaxNumberOfMessages"))
+	wait, _ := strconv.Atoi(r.Form.Get("WaitTimeSeconds"))
+	timeout := time.After(time.Duration(wait) * time.Second)
+
+	for {
+		messages := f.take(max)
+		if len(messages) > 0 {
+			var result strings.Builder
+			for _, message := range messages {
+				checksum := md5.Sum([]byte(message.body))
+				fmt.Fprintf(&result,
+					"<Message><MessageId>%s</MessageId><ReceiptHandle>%s</ReceiptHandle><MD5OfBody>%s</MD5OfBody><Body>%s</Body>"+
+						"<Attribute><Name>ApproximateReceiveCount</Name><Value>%d</Value></Attribute></Message>",
+					message.id, receiptHandle(message), hex.EncodeToString(checksum[:]), escape(message.body), message.receiveCount)
+			}
+			f.respond(w, "ReceiveMessage", "<ReceiveMessageResult>"+result.String()+"</ReceiveMessageResult>")
+			return
+		}
+
+		select {
+		case <-f.arrived:
+		case <-timeout:
+			f.respond(w, "ReceiveMessage", "<ReceiveMessageResult></ReceiveMessageResult>")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (f *fakeAWS) take(max int) []fakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if max <= 0 || max > len(f.queue) {
+		max = len(f.queue)
+	}
+
+	messages := f.queue[:max]
+	f.queue = f.queue[max:]
+	for i := range messages {
+		messages[i].receiveCount++
+		f.inFlight[receiptHandle(messages[i])] = messages[i]
+	}
+	return messages
+}
+
+func (f *fakeAWS) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	f.delete(r.Form.Get("ReceiptHandle"))
+	f.respond(w, "DeleteMessage", "")
+}
+
+func (f *fakeAWS) deleteMessageBatch(w http.ResponseWriter, r *http.Request) {
+	var result strings.Builder
+	for i := 1; ; i++ {
+		prefix := "DeleteMessageBatchRequestEntry." + strconv.Itoa(i) + "."
+		handle := r.Form.Get(prefix + "ReceiptHandle")
+		if handle == "" {
+			break
+		}
+
+		f.delete(handle)
+		result.WriteString("<DeleteMessageBatchResultEntry><Id>" + escape(r.Form.Get(prefix+"Id")) + "</Id></DeleteMessageBatchResultEntry>")
+	}
+
+	f.respond(w, "DeleteMessageBatch", "<DeleteMessageBatchResult>"+result.String()+"</DeleteMessageBatchResult>")
+}
+
+func (f *fakeAWS) delete(handle string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.inFlight[handle]; ok {
+		delete(f.inFlight, handle)
+		f.deleted = append(f.deleted, handle)
+	}
+}
+
+// changeMessageVisibility only honours a zero timeout, which makes the
+// message available again right away.
+func (f *fakeAWS) changeMessageVisibility(w http.ResponseWriter, r *http.Request) {
+	handle := r.Form.Get("ReceiptHandle")
+
+	f.mu.Lock()
+	if message, ok := f.inFlight[handle]; ok && r.Form.Get("VisibilityTimeout") == "0" {
+		delete(f.inFlight, handle)
+		f.queue = append(f.queue, message)
+	}
+	f.mu.Unlock()
+
+	f.respond(w, "ChangeMessageVisibility", "")
+}
+
+func (f *fakeAWS) respond(w http.ResponseWriter, action string, result string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, "<%sResponse>%s<ResponseMetadata><RequestId>fake</RequestId></ResponseMetadata></%sResponse>", action, result, action)
+}
+
+func (f *fakeAWS) fail(w http.ResponseWriter, code string, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, "<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>fake</RequestId></ErrorResponse>", code, escape(message))
+}
+
+func (f *fakeAWS) state() (queued int, inFlight int, deleted int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queue), len(f.inFlight), len(f.deleted)
+}
+
+func receiptHandle(message fakeMessage) string {
+	return message.id + "-" + strconv.Itoa(message.receiveCount)
+}
+
+func escape(s string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(s))
+	return buffer.String()
+}
+
+// awsConfig points both SDKs at the stand-in server. The endpoint has no
+// scheme, so the clients only speak plain HTTP if disabling SSL works.
+func awsConfig(server *httptest.Server) *configs.Config {
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	config := &configs.Config{}
+	config.Event.Producer.SNS.Endpoint = endpoint
+	config.Event.Producer.SNS.DisableSSL = true
+	config.Event.Producer.SNS.ForcePathStyle = true
+	config.Event.Producer.SNS.Region = "us-east-1"
+	config.Event.Producer.SNS.AccessKeyID = "test"
+	config.Event.Producer.SNS.SecretAccessKey = "test"
+	config.Event.Producer.SNS.MaxRetries = 1
+
+	config.Event.Consumer.SQS.Endpoint = endpoint
+	config.Event.Consumer.SQS.DisableSSL = true
+	config.Event.Consumer.SQS.ForcePathStyle = true
+	config.Event.Consumer.SQS.Region = "us-east-1"
+	config.Event.Consumer.SQS.AccessKeyID = "test"
+	config.Event.Consumer.SQS.SecretAccessKey = "test"
+	config.Event.Consumer.SQS.MaxMessage = 10
+	config.Event.Consumer.SQS.WaitTimeSeconds = 1
+	config.Event.Consumer.SQS.Workers = 2
+
+	return config
+}
+
+func TestAWSIntegration(t *testing.T) {
+	fake := newFakeAWS()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config := awsConfig(server)
+	topic := "arn:aws:sns:us-east-1:000000000000:foo-created"
+	otherTopic := "arn:aws:sns:us-east-1:000000000000:foo-updated.fifo"
+	queueURL := server.URL + "/000000000000/foobarbaz"
+	snsProducer := producer.NewSNSProducer(config)
+
+	t.Run("Publishes with attributes", func(t *testing.T) {
+		result, err := snsProducer.PublishWithContext(context.Background(), model.PublishRequest{
+			Attributes: map[string]string{"eventType": "test"},
+			Event:      model.NewEvent("test", "single"),
+			Topic:      topic,
+		})
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, result.MessageID)
+		assert.Equal(t, map[string]string{"eventType": "test"}, fake.published[0].attributes)
+	})
+
+	t.Run("Publishes a batch per topic", func(t *testing.T) {
+		requests := make([]model.PublishRequest, 0)
+		for i := 0; i < 11; i++ {
+			requests = append(requests, model.PublishRequest{
+				Event: model.NewEvent("test", "batch"+strconv.Itoa(i)),
+				Topic: topic,
+			})
+		}
+		requests = append(requests, model.PublishRequest{
+			DeduplicationID: aws.String("dedup"),
+			Event:           model.NewEvent("test", "fifo"),
+			MessageGroupID:  aws.String("group"),
+			Topic:           otherTopic,
+		}, model.PublishRequest{
+			Event: model.NewEvent("test", "reject"),
+			Topic: topic,
+		})
+
+		results, err := snsProducer.PublishBatch(context.Background(), requests)
+
+		var entryErr *producer.BatchEntryError
+		assert.True(t, errors.As(err, &entryErr))
+		assert.True(t, entryErr.SenderFault)
+		assert.Len(t, results, 13)
+		for i, result := range results[:12] {
+			assert.NoError(t, result.Err, i)
+			assert.NotEmpty(t, result.MessageID, i)
+		}
+		assert.Error(t, results[12].Err)
+
+		assert.Len(t, fake.published, 13)
+		assert.Equal(t, otherTopic, fake.published[12].topic)
+		assert.Equal(t, "dedup", fake.published[12].deduplicationID)
+	})
+
+	t.Run("Receives and deletes", func(t *testing.T) {
+		r := &received{}
+		sqsConsumer := consumer.NewSQSConsumer(config)
+		sqsConsumer.Process = r.process
+
+		go sqsConsumer.Listen(queueURL)
+		assert.Eventually(t, func() bool { return r.count() == 13 }, 5*time.Second, 10*time.Millisecond)
+		sqsConsumer.Stop()
+
+		assert.Contains(t, r.messages, `"single"`)
+		assert.Contains(t, r.messages, `"fifo"`)
+
+		queued, inFlight, deleted := fake.state()
+		assert.Equal(t, 0, queued)
+		assert.Equal(t, 0, inFlight)
+		assert.Equal(t, 13, deleted)
+	})
+}
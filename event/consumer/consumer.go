@@ -1,5 +1,37 @@
 package consumer
 
+import "time"
+
+// Consumer represents an event consumer interface.
 type Consumer interface {
 	Listen(url string)
+	Stop()
+	Health() Health
+}
+
+// Status is the state of a consumer.
+type Status string
+
+const (
+	// StatusIdle indicates a consumer that has not started listening.
+	StatusIdle Status = "idle"
+	// StatusRunning indicates a consumer that is polling its queue.
+	StatusRunning Status = "running"
+	// StatusStopped indicates a consumer that was stopped on shutdown.
+	StatusStopped Status = "stopped"
+	// StatusFailed indicates a consumer that gave up polling its queue.
+	StatusFailed Status = "failed"
+)
+
+// Health describes the state of a consumer as reported on the health
+// endpoint.
+type Health struct {
+	Status       Status     `json:"status"`
+	LastPolledAt *time.Time `json:"lastPolledAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// IsHealthy reports whether the consumer is working as expected.
+func (h Health) IsHealthy() bool {
+	return h.Status != StatusFailed
 }
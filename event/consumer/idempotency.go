@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultIdempotencyLeaseSeconds = 300
+	defaultIdempotencyTTLSeconds   = 7 * 24 * 60 * 60
+)
+
+// ErrInProgress is returned for a message that another delivery is still
+// processing. It is retryable, so the message comes back once the other
+// delivery is done and is then skipped.
+var ErrInProgress = errors.New("message is already being processed")
+
+// ClaimResult is the outcome of claiming a message in a Ledger.
+type ClaimResult int
+
+const (
+	// Claimed indicates the message is reserved for this delivery.
+	Claimed ClaimResult = iota + 1
+	// AlreadyProcessed indicates the message was processed before.
+	AlreadyProcessed
+	// InProgress indicates another delivery holds an unexpired claim.
+	InProgress
+)
+
+// Ledger records which messages were processed, so that duplicate deliveries
+// can be skipped.
+type Ledger interface {
+	// Claim reserves a message for processing for the duration of lease,
+	// unless it was already processed or is reserved by another delivery.
+	Claim(key string, lease time.Duration) (result ClaimResult, err error)
+	// Complete marks a claimed message as processed and remembers it for ttl.
+	Complete(key string, ttl time.Duration) (err error)
+	// Release gives up a claim after processing failed, so the message can
+	// be processed again.
+	Release(key string) (err error)
+}
+
+// ProvideLedger is the provider for the Ledger selected by
+// Event.Consumer.Idempotency.Driver, either mysql (the default) or redis.
+func ProvideLedger(config *configs.Config, db *infras.MySQLConn) Ledger {
+	if config.Event.Consumer.Idempotency.Driver == "redis" {
+		return &RedisLedger{Client: infras.RedisNewClient(*config)}
+	}
+
+	return &MySQLLedger{DB: db}
+}
+
+// Idempotent wraps a Process so that each message, identified by the
+// MessageId of its SNS envelope, is processed successfully at most once.
+// Handlers consuming the same message, e.g. from queues subscribed to the
+// same topic, must use different names. Messages without a MessageId are
+// processed every time they are delivered.
+func Idempotent(name string, ledger Ledger, config *configs.Config, process Process) Process {
+	idempotency := config.Event.Consumer.Idempotency
+
+	lease := time.Duration(idempotency.LeaseSeconds) * time.Second
+	if lease <= 0 {
+		lease = defaultIdempotencyLeaseSeconds * time.Second
+	}
+
+	ttl := time.Duration(idempotency.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTLSeconds * time.Second
+	}
+
+	return func(ctx context.Context, value []byte) (err error) {
+		snsMessage := model.SNSMessage{}
+		if err := json.Unmarshal(value, &snsMessage); err != nil {
+			// let the handler decide what to do with a malformed message
+			return process(ctx, value)
+		}
+
+		// without an ID, messages cannot be told apart; sharing a key would
+		// drop every one but the first
+		if snsMessage.MessageID == uuid.Nil {
+			log.Warn().Str("name", name).Msg("Message has no MessageId, processing it without deduplication.")
+			return process(ctx, value)
+		}
+
+		key := name + ":" + snsMessage.MessageID.String()
+
+		result, err := ledger.Claim(key, lease)
+		if err != nil {
+			return
+		}
+
+		switch result {
+		case AlreadyProcessed:
+			log.Info().Str("key", key).Msg("Skipping message that was already processed.")
+			return nil
+		case InProgress:
+			return ErrInProgress
+		}
+
+		err = process(ctx, value)
+		if err != nil {
+			if releaseErr := ledger.Release(key); releaseErr != nil {
+				log.Error().Err(releaseErr).Str("key", key).Msg("failed releasing message claim")
+			}
+			return
+		}
+
+		return ledger.Complete(key, ttl)
+	}
+}
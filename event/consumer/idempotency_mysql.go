@@ -0,0 +1,108 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+)
+
+var (
+	ledgerQueries = struct {
+		claimMessage    string
+		selectStatus    string
+		completeMessage string
+		releaseMessage  string
+	}{
+		// the existing row is only taken over once its expiry has passed;
+		// status is assigned first so both comparisons see the old expiry
+		claimMessage: `
+			INSERT INTO processed_messages (
+				message_key,
+				status,
+				expires_at
+			) VALUES (
+				?,
+				'processing',
+				?
+			)
+			ON DUPLICATE KEY UPDATE
+				status = IF(expires_at < ?, 'processing', status),
+				expires_at = IF(expires_at < ?, VALUES(expires_at), expires_at)
+		`,
+		selectStatus: `
+			SELECT status FROM processed_messages WHERE message_key = ?
+		`,
+		completeMessage: `
+			UPDATE processed_messages
+			SET
+				status = 'done',
+				expires_at = ?
+			WHERE
+				message_key = ?
+		`,
+		releaseMessage: `
+			DELETE FROM processed_messages WHERE message_key = ? AND status = 'processing'
+		`,
+	}
+)
+
+// MySQLLedger is the MySQL-backed implementation of Ledger. Expired rows are
+// purged by the janitor.
+type MySQLLedger struct {
+	DB *infras.MySQLConn
+}
+
+// Claim implements Ledger.
+func (l *MySQLLedger) Claim(key string, lease time.Duration) (result ClaimResult, err error) {
+	now := time.Now()
+	res, err := l.DB.Write.Exec(ledgerQueries.claimMessage, key, now.Add(lease), now, now)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	// 1 for an insert and 2 for a takeover; 0 when the row was left as is
+	affected, err := res.RowsAffected()
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if affected > 0 {
+		return Claimed, nil
+	}
+
+	var status string
+	err = l.DB.Write.Get(&status, ledgerQueries.selectStatus, key)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if status == "done" {
+		return AlreadyProcessed, nil
+	}
+
+	return InProgress, nil
+}
+
+// Complete implements Ledger.
+func (l *MySQLLedger) Complete(key string, ttl time.Duration) (err error) {
+	_, err = l.DB.Write.Exec(ledgerQueries.completeMessage, time.Now().Add(ttl), key)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// Release implements Ledger.
+func (l *MySQLLedger) Release(key string) (err error) {
+	_, err = l.DB.Write.Exec(ledgerQueries.releaseMessage, key)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/go-redis/redis"
+)
+
+const (
+	ledgerStatusProcessing = "processing"
+	ledgerStatusDone       = "done"
+	ledgerKeyPrefix        = "processed-message:"
+)
+
+// releaseScript deletes a claim only while it is still in progress.
+var releaseScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+// RedisLedger is the Redis-backed implementation of Ledger. Entries expire on
+// their own.
+type RedisLedger struct {
+	Client *redis.Client
+}
+
+// Claim implements Ledger.
+func (l *RedisLedger) Claim(key string, lease time.Duration) (result ClaimResult, err error) {
+	claimed, err := l.Client.SetNX(ledgerKeyPrefix+key, ledgerStatusProcessing, lease).Result()
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if claimed {
+		return Claimed, nil
+	}
+
+	status, err := l.Client.Get(ledgerKeyPrefix + key).Result()
+	if err == redis.Nil {
+		// the other claim expired in the meantime; retry later
+		return InProgress, nil
+	}
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	if status == ledgerStatusDone {
+		return AlreadyProcessed, nil
+	}
+
+	return InProgress, nil
+}
+
+// Complete implements Ledger.
+func (l *RedisLedger) Complete(key string, ttl time.Duration) (err error) {
+	err = l.Client.Set(ledgerKeyPrefix+key, ledgerStatusDone, ttl).Err()
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+
+	return
+}
+
+// Release implements Ledger.
+func (l *RedisLedger) Release(key string) (err error) {
+	err = releaseScript.Run(l.Client, []string{ledgerKeyPrefix + key}, ledgerStatusProcessing).Err()
+	if err != nil && err != redis.Nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+
+	return nil
+}
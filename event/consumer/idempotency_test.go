@@ -0,0 +1,90 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryLedger is an in-memory Ledger without leases.
+type memoryLedger map[string]string
+
+func (l memoryLedger) Claim(key string, lease time.Duration) (ClaimResult, error) {
+	switch l[key] {
+	case "done":
+		return AlreadyProcessed, nil
+	case "processing":
+		return InProgress, nil
+	}
+	l[key] = "processing"
+	return Claimed, nil
+}
+
+func (l memoryLedger) Complete(key string, ttl time.Duration) error {
+	l[key] = "done"
+	return nil
+}
+
+func (l memoryLedger) Release(key string) error {
+	delete(l, key)
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	message := []byte(`{"MessageId":"0b5a4f6e-9d1c-4f5e-8f1a-2f6d1c3b9a7e","Message":"{}"}`)
+
+	t.Run("Processes a message once", func(t *testing.T) {
+		calls := 0
+		process := Idempotent("test", memoryLedger{}, &configs.Config{}, func(context.Context, []byte) error {
+			calls++
+			return nil
+		})
+
+		assert.NoError(t, process(context.Background(), message))
+		assert.NoError(t, process(context.Background(), message))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Processes a message again after a failure", func(t *testing.T) {
+		calls := 0
+		process := Idempotent("test", memoryLedger{}, &configs.Config{}, func(context.Context, []byte) error {
+			calls++
+			if calls == 1 {
+				return errors.New("database down")
+			}
+			return nil
+		})
+
+		assert.Error(t, process(context.Background(), message))
+		assert.NoError(t, process(context.Background(), message))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Retries a message claimed by another delivery", func(t *testing.T) {
+		ledger := memoryLedger{"test:0b5a4f6e-9d1c-4f5e-8f1a-2f6d1c3b9a7e": "processing"}
+		process := Idempotent("test", ledger, &configs.Config{}, func(context.Context, []byte) error {
+			return nil
+		})
+
+		assert.Equal(t, ErrInProgress, process(context.Background(), message))
+		assert.False(t, IsPermanent(ErrInProgress))
+	})
+
+	t.Run("Processes messages without an ID every time", func(t *testing.T) {
+		ledger := memoryLedger{}
+		calls := 0
+		process := Idempotent("test", ledger, &configs.Config{}, func(context.Context, []byte) error {
+			calls++
+			return nil
+		})
+
+		assert.NoError(t, process(context.Background(), []byte(`{"Message":"first"}`)))
+		assert.NoError(t, process(context.Background(), []byte(`{"Message":"second"}`)))
+		assert.Equal(t, 2, calls)
+		assert.Empty(t, ledger)
+	})
+}
@@ -0,0 +1,77 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// lifecycle tracks the state of a consumer's listeners so that it can be
+// stopped gracefully and report its health.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	health Health
+}
+
+func newLifecycle() lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return lifecycle{
+		ctx:    ctx,
+		cancel: cancel,
+		health: Health{Status: StatusIdle},
+	}
+}
+
+// begin registers a listener. It returns false once the consumer is stopped;
+// otherwise the listener must call end when it returns.
+func (l *lifecycle) begin() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.ctx.Err() != nil {
+		return false
+	}
+	l.wg.Add(1)
+
+	return true
+}
+
+func (l *lifecycle) end() {
+	l.wg.Done()
+}
+
+// Stop stops listening and waits for messages in flight to be processed.
+func (l *lifecycle) Stop() {
+	l.mu.Lock()
+	l.cancel()
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// Health returns the current state of this consumer.
+func (l *lifecycle) Health() Health {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.health
+}
+
+func (l *lifecycle) setStatus(status Status, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.health.Status = status
+	l.health.Error = ""
+	if err != nil {
+		l.health.Error = err.Error()
+	}
+
+	if status == StatusRunning {
+		now := time.Now()
+		l.health.LastPolledAt = &now
+	}
+}
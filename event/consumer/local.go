@@ -0,0 +1,190 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultFilePollIntervalMilliseconds = 500
+
+// NewConsumer creates the Consumer selected by Event.Driver: aws (the
+// default), memory or file. Messages that cannot be processed are moved to
+// deadLetterURL when consuming from SQS.
+func NewConsumer(config *configs.Config, process Process, deadLetterURL string) Consumer {
+	switch config.Event.Driver {
+	case broker.DriverMemory:
+		return NewMemoryConsumer(broker.DefaultMemory(), process)
+	case broker.DriverFile:
+		return NewFileConsumer(config, &broker.File{Directory: config.Event.File.Directory}, process)
+	default:
+		sqsConsumer := NewSQSConsumer(config)
+		sqsConsumer.Process = process
+		sqsConsumer.DeadLetterURL = deadLetterURL
+		return sqsConsumer
+	}
+}
+
+// MemoryConsumer consumes from the in-process broker. Messages live only in
+// memory: one that fails processing is logged and dropped.
+type MemoryConsumer struct {
+	Process Process
+	broker  *broker.Memory
+	lifecycle
+}
+
+// NewMemoryConsumer creates a consumer listening on an in-process broker.
+func NewMemoryConsumer(memory *broker.Memory, process Process) *MemoryConsumer {
+	return &MemoryConsumer{
+		Process:   process,
+		broker:    memory,
+		lifecycle: newLifecycle(),
+	}
+}
+
+// Listen processes the messages published to a name until the consumer is
+// stopped.
+func (c *MemoryConsumer) Listen(name string) {
+	if !c.begin() {
+		return
+	}
+	defer c.end()
+
+	deliveries, unsubscribe := c.broker.Subscribe(name)
+	defer unsubscribe()
+
+	log.Info().Str("name", name).Msg("Memory Consumer is listening.")
+	c.setStatus(StatusRunning, nil)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.setStatus(StatusStopped, nil)
+			log.Info().Str("name", name).Msg("Memory Consumer stopped listening.")
+			return
+		case body := <-deliveries:
+			ctx, span := StartSpan(context.Background(), broker.DriverMemory, name, body)
+			err := c.Process(ctx, body)
+			tracing.End(span, err)
+			if err != nil {
+				log.Error().Err(err).Str("name", name).Msg("failed processing message, dropping it")
+			}
+		}
+	}
+}
+
+// FileConsumer consumes from a directory-spooled broker, polling for new
+// messages. A message that fails with a retryable error is retried on the
+// next poll; one that fails permanently or too often is moved to the failed
+// subdirectory.
+type FileConsumer struct {
+	Process  Process
+	config   *configs.Config
+	broker   *broker.File
+	attempts map[string]int
+	lifecycle
+}
+
+// NewFileConsumer creates a consumer polling a directory-spooled broker.
+func NewFileConsumer(config *configs.Config, file *broker.File, process Process) *FileConsumer {
+	return &FileConsumer{
+		Process:   process,
+		config:    config,
+		broker:    file,
+		attempts:  make(map[string]int),
+		lifecycle: newLifecycle(),
+	}
+}
+
+// Listen processes the messages spooled for a name until the consumer is
+// stopped.
+func (c *FileConsumer) Listen(name string) {
+	if !c.begin() {
+		return
+	}
+	defer c.end()
+
+	log.Info().Str("name", name).Str("directory", c.broker.Directory).Msg("File Consumer will start polling.")
+
+	ticker := time.NewTicker(c.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.setStatus(StatusStopped, nil)
+			log.Info().Str("name", name).Msg("File Consumer stopped polling.")
+			return
+		case <-ticker.C:
+			c.poll(name)
+		}
+	}
+}
+
+func (c *FileConsumer) poll(name string) {
+	paths, err := c.broker.Pending(name)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed listing messages")
+		c.setStatus(StatusFailed, err)
+		return
+	}
+	c.setStatus(StatusRunning, nil)
+
+	for _, path := range paths {
+		if c.ctx.Err() != nil {
+			return
+		}
+
+		// stop at the first failure so later messages do not overtake it
+		if !c.handle(name, path) {
+			return
+		}
+	}
+}
+
+// handle processes a single message and reports whether it was settled.
+func (c *FileConsumer) handle(name string, path string) (settled bool) {
+	body, err := c.broker.Read(path)
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed reading message")
+		return false
+	}
+
+	ctx, span := StartSpan(context.Background(), broker.DriverFile, name, body)
+	err = c.Process(ctx, body)
+	tracing.End(span, err)
+	if err == nil {
+		delete(c.attempts, path)
+		if err := c.broker.Ack(path); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed deleting message")
+		}
+		return true
+	}
+
+	c.attempts[path]++
+	logMsg := log.Error().Err(err).Str("path", path).Int("receiveCount", c.attempts[path])
+
+	if IsPermanent(err) || c.attempts[path] >= maxReceiveCount(c.config) {
+		logMsg.Bool("permanent", IsPermanent(err)).Msg("failed processing message, moving it to failed")
+		delete(c.attempts, path)
+		if err := c.broker.Reject(path); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed moving message")
+			return false
+		}
+		return true
+	}
+
+	logMsg.Msg("failed processing message, will retry")
+	return false
+}
+
+func (c *FileConsumer) pollInterval() time.Duration {
+	if c.config.Event.File.PollIntervalMilliseconds <= 0 {
+		return defaultFilePollIntervalMilliseconds * time.Millisecond
+	}
+	return time.Duration(c.config.Event.File.PollIntervalMilliseconds) * time.Millisecond
+}
@@ -0,0 +1,107 @@
+package consumer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// received collects the data of the events a consumer processed.
+type received struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *received) process(ctx context.Context, body []byte) error {
+	return consumer.NewRouter("test").Handle("test", r.handle).Process(ctx, body)
+}
+
+func (r *received) handle(ctx context.Context, event model.CloudEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, string(event.Data))
+
+	if string(event.Data) == `"poison"` {
+		return failure.BadRequestFromString("poison")
+	}
+	return nil
+}
+
+func (r *received) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messages)
+}
+
+func publish(t *testing.T, p producer.Producer, topic string, values ...string) {
+	for _, value := range values {
+		err := p.Publish(model.PublishRequest{
+			Event: model.NewEvent("test", value),
+			Topic: topic,
+		})
+		assert.NoError(t, err)
+	}
+}
+
+func TestLocalConsumers(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		config := &configs.Config{}
+		config.Event.Driver = broker.DriverMemory
+
+		r := &received{}
+		c := consumer.NewConsumer(config, r.process, "")
+		go c.Listen("memory-topic")
+		assert.Eventually(t, func() bool {
+			return c.Health().Status == consumer.StatusRunning
+		}, time.Second, time.Millisecond)
+
+		publish(t, producer.ProvideProducer(config), "memory-topic", "first", "second")
+
+		assert.Eventually(t, func() bool { return r.count() == 2 }, time.Second, time.Millisecond)
+		c.Stop()
+		assert.Equal(t, []string{`"first"`, `"second"`}, r.messages)
+		assert.Equal(t, consumer.StatusStopped, c.Health().Status)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "events")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		config := &configs.Config{}
+		config.Event.Driver = broker.DriverFile
+		config.Event.File.Directory = dir
+		config.Event.File.PollIntervalMilliseconds = 10
+
+		topic := "arn:aws:sns:ap-southeast-1:000000000000:file-topic"
+		publish(t, producer.ProvideProducer(config), topic, "first", "poison", "second")
+
+		r := &received{}
+		c := consumer.NewConsumer(config, r.process, "")
+		go c.Listen(topic)
+
+		assert.Eventually(t, func() bool { return r.count() == 3 }, time.Second, time.Millisecond)
+		c.Stop()
+		assert.Equal(t, []string{`"first"`, `"poison"`, `"second"`}, r.messages)
+
+		pending, err := (&broker.File{Directory: dir}).Pending(topic)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+
+		failed, err := filepath.Glob(filepath.Join(dir, "*", "failed", "*.json"))
+		assert.NoError(t, err)
+		assert.Len(t, failed, 1)
+	})
+}
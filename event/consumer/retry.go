@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/failure"
+)
+
+const (
+	defaultMaxReceiveCount          = 5
+	defaultVisibilityBackoffSeconds = 30
+	// maxVisibilityTimeout is the longest visibility timeout SQS accepts.
+	maxVisibilityTimeout = 12 * time.Hour
+)
+
+// IsPermanent reports whether a processing error will never succeed on a
+// retry. Failures with a 4xx code, e.g. a malformed message or a conflict, are
+// permanent; everything else is assumed to be transient.
+func IsPermanent(err error) bool {
+	code := failure.GetCode(err)
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError
+}
+
+// maxReceiveCount returns how often a message may fail before it is given up
+// on.
+func maxReceiveCount(config *configs.Config) int {
+	if config.Event.Consumer.SQS.MaxReceiveCount <= 0 {
+		return defaultMaxReceiveCount
+	}
+	return config.Event.Consumer.SQS.MaxReceiveCount
+}
+
+// visibilityBackoff returns how long a message that failed on its nth receive
+// stays invisible before it is redelivered, doubling from base on every
+// receive.
+func visibilityBackoff(base time.Duration, receiveCount int) time.Duration {
+	if receiveCount < 1 {
+		receiveCount = 1
+	}
+
+	backoff := base
+	for i := 1; i < receiveCount; i++ {
+		backoff *= 2
+		if backoff >= maxVisibilityTimeout {
+			return maxVisibilityTimeout
+		}
+	}
+
+	return backoff
+}
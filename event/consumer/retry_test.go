@@ -0,0 +1,24 @@
+package consumer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermanent(t *testing.T) {
+	assert.True(t, IsPermanent(failure.BadRequestFromString("malformed")))
+	assert.True(t, IsPermanent(failure.Conflict("create", "foo", "already exists")))
+	assert.False(t, IsPermanent(failure.InternalError(errors.New("database down"))))
+	assert.False(t, IsPermanent(errors.New("timeout")))
+}
+
+func TestVisibilityBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, visibilityBackoff(30*time.Second, 0))
+	assert.Equal(t, 30*time.Second, visibilityBackoff(30*time.Second, 1))
+	assert.Equal(t, 2*time.Minute, visibilityBackoff(30*time.Second, 3))
+	assert.Equal(t, maxVisibilityTimeout, visibilityBackoff(30*time.Second, 50))
+}
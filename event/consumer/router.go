@@ -0,0 +1,115 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// Handler processes a single event. ctx carries the trace of its message.
+type Handler func(ctx context.Context, event model.CloudEvent) error
+
+// Router decodes the SNS messages a consumer receives and hands each event to
+// the handler registered for its type. Messages are CloudEvents, or legacy
+// payloads holding only the data. Legacy payloads are given LegacyType, and
+// the ID, topic and time of their SNS envelope. Events of a type without a
+// handler are skipped, as a topic may carry events this consumer does not
+// care about. The data of every other event is validated against Schemas.
+type Router struct {
+	LegacyType string
+	Schemas    *model.SchemaRegistry
+	handlers   map[string]Handler
+}
+
+// NewRouter creates a Router treating legacy payloads as events of
+// legacyType.
+func NewRouter(legacyType string) *Router {
+	return &Router{
+		LegacyType: legacyType,
+		Schemas:    model.Schemas,
+		handlers:   make(map[string]Handler),
+	}
+}
+
+// Handle registers the handler for an event type.
+func (r *Router) Handle(eventType string, handler Handler) *Router {
+	r.handlers[eventType] = handler
+	return r
+}
+
+// Process decodes a message and routes its event. Messages that cannot be
+// decoded fail permanently. Consumed events are counted by type and result.
+func (r *Router) Process(ctx context.Context, body []byte) error {
+	snsMessage := model.SNSMessage{}
+	if err := json.Unmarshal(body, &snsMessage); err != nil {
+		countConsumed(unknownEventType, err)
+		return failure.BadRequest(err)
+	}
+
+	event, err := r.decode(snsMessage)
+	if err != nil {
+		countConsumed(unknownEventType, err)
+		return failure.BadRequest(err)
+	}
+
+	handler, ok := r.handlers[event.Type]
+	if !ok {
+		metrics.EventsConsumed.WithLabelValues(event.Type, metrics.ResultSkipped).Inc()
+		log.Warn().
+			Str("id", event.ID).
+			Str("type", event.Type).
+			Msg("No handler for event type, skipping.")
+		return nil
+	}
+
+	err = r.Schemas.Validate(event.Type, event.SchemaVersion, event.Data)
+	if err != nil {
+		countConsumed(event.Type, err)
+		return failure.BadRequest(err)
+	}
+
+	// whatever the handler logs is correlated with the event
+	eventLogger := logger.FromContext(ctx).With().Str("eventId", event.ID).Str("eventType", event.Type).Logger()
+	err = handler(logger.NewContext(ctx, eventLogger), event)
+	countConsumed(event.Type, err)
+	return err
+}
+
+// unknownEventType labels messages whose event could not be decoded.
+const unknownEventType = "unknown"
+
+func countConsumed(eventType string, err error) {
+	metrics.EventsConsumed.WithLabelValues(eventType, metrics.Result(err)).Inc()
+}
+
+func (r *Router) decode(snsMessage model.SNSMessage) (event model.CloudEvent, err error) {
+	message := []byte(snsMessage.Message)
+
+	event, ok, err := model.DecodeCloudEvent(message)
+	if ok || err != nil {
+		return
+	}
+
+	if !json.Valid(message) {
+		return event, errors.New("message is neither a cloud event nor JSON")
+	}
+
+	timestamp, _ := time.Parse(time.RFC3339Nano, snsMessage.Timestamp)
+
+	return model.CloudEvent{
+		ID:              snsMessage.MessageID.String(),
+		Source:          snsMessage.TopicARN,
+		SpecVersion:     model.CloudEventsSpecVersion,
+		Type:            r.LegacyType,
+		DataContentType: model.JSONContentType,
+		Time:            timestamp,
+		Data:            json.RawMessage(message),
+	}, nil
+}
@@ -0,0 +1,78 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func envelope(t *testing.T, message []byte) []byte {
+	body, err := broker.Wrap("topic", message, nil)
+	assert.NoError(t, err)
+	return body
+}
+
+func TestRouter(t *testing.T) {
+	handled := make([]model.CloudEvent, 0)
+	handle := func(ctx context.Context, event model.CloudEvent) error {
+		handled = append(handled, event)
+		return nil
+	}
+	router := consumer.NewRouter("legacy").
+		Handle("legacy", handle).
+		Handle("foo.created", handle)
+
+	t.Run("Routes cloud events by type", func(t *testing.T) {
+		handled = handled[:0]
+		event := model.NewEvent("foo.created", map[string]string{"id": "1"}).WithSubject("1").ToCloudEvent("test")
+		message, _ := json.Marshal(event)
+
+		assert.NoError(t, router.Process(context.Background(), envelope(t, message)))
+		assert.Len(t, handled, 1)
+		assert.Equal(t, event.ID, handled[0].ID)
+		assert.Equal(t, "1", handled[0].Subject)
+		assert.JSONEq(t, `{"id":"1"}`, string(handled[0].Data))
+	})
+
+	t.Run("Decodes legacy payloads", func(t *testing.T) {
+		handled = handled[:0]
+
+		assert.NoError(t, router.Process(context.Background(), envelope(t, []byte(`{"name":"foo"}`))))
+		assert.Len(t, handled, 1)
+		assert.Equal(t, "legacy", handled[0].Type)
+		assert.Equal(t, "topic", handled[0].Source)
+		assert.NotEmpty(t, handled[0].ID)
+		assert.JSONEq(t, `{"name":"foo"}`, string(handled[0].Data))
+	})
+
+	t.Run("Skips unknown types", func(t *testing.T) {
+		handled = handled[:0]
+		message, _ := json.Marshal(model.NewEvent("bar.created", nil).ToCloudEvent("test"))
+
+		assert.NoError(t, router.Process(context.Background(), envelope(t, message)))
+		assert.Empty(t, handled)
+	})
+
+	t.Run("Fails permanently on data not matching its schema", func(t *testing.T) {
+		handled = handled[:0]
+		router.Schemas = model.NewSchemaRegistry().
+			MustRegister("foo.created", 1, `{"type": "object", "required": ["id"]}`)
+		defer func() { router.Schemas = model.Schemas }()
+		message, _ := json.Marshal(model.NewEvent("foo.created", map[string]string{}).ToCloudEvent("test"))
+
+		assert.True(t, consumer.IsPermanent(router.Process(context.Background(), envelope(t, message))))
+		assert.Empty(t, handled)
+	})
+
+	t.Run("Fails permanently on malformed messages", func(t *testing.T) {
+		for _, message := range []string{"not json", `{"specversion":"1.0"}`} {
+			err := router.Process(context.Background(), envelope(t, []byte(message)))
+			assert.True(t, consumer.IsPermanent(err), message)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package consumer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/failure"
+)
+
+const (
+	certificateFetchTimeout = 10 * time.Second
+	// maxCertificateSize bounds what is read from a signing certificate URL.
+	maxCertificateSize = 64 * 1024
+)
+
+// defaultCertificateHost matches the hosts SNS serves its signing
+// certificates from.
+var defaultCertificateHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+// Verifier verifies the signatures of SNS messages, so a consumer only
+// processes messages that really came from SNS. Signing certificates are
+// fetched over HTTPS, only from allowed hosts, and cached until they expire.
+// AllowedHosts lists the hosts, with their port if any, certificates may be
+// fetched from; when empty, only the SNS hosts of AWS are allowed.
+type Verifier struct {
+	Enabled      bool
+	AllowedHosts []string
+	Client       *http.Client
+	mu           sync.Mutex
+	certificates map[string]*x509.Certificate
+	fetches      map[string]*certificateFetch
+}
+
+// certificateFetch is a certificate download in progress, shared by every
+// verification waiting for the same certificate.
+type certificateFetch struct {
+	done        chan struct{}
+	certificate *x509.Certificate
+	err         error
+}
+
+// ProvideVerifier is the provider for Verifier.
+func ProvideVerifier(config *configs.Config) *Verifier {
+	return &Verifier{
+		Enabled:      config.Event.Consumer.Signature.Enabled,
+		AllowedHosts: config.Event.Consumer.Signature.AllowedHosts,
+		Client:       &http.Client{Timeout: certificateFetchTimeout},
+		certificates: make(map[string]*x509.Certificate),
+	}
+}
+
+// Verified wraps process so it only sees SNS messages with a valid signature,
+// if the verifier is enabled. Messages that are not signed or whose signature
+// does not match fail permanently; failing to fetch a signing certificate is
+// retried.
+func Verified(verifier *Verifier, process Process) Process {
+	if verifier == nil || !verifier.Enabled {
+		return process
+	}
+
+	return func(ctx context.Context, value []byte) error {
+		snsMessage := model.SNSMessage{}
+		if err := json.Unmarshal(value, &snsMessage); err != nil {
+			return failure.BadRequest(err)
+		}
+
+		if err := verifier.Verify(snsMessage); err != nil {
+			return err
+		}
+
+		return process(ctx, value)
+	}
+}
+
+// Verify verifies the signature of an SNS message. Signature version 1 is
+// signed with SHA1, version 2 with SHA256.
+func (v *Verifier) Verify(message model.SNSMessage) error {
+	var hash crypto.Hash
+	switch message.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return failure.BadRequestFromString(fmt.Sprintf("unsupported SNS signature version %q", message.SignatureVersion))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(message.Signature)
+	if err != nil {
+		return failure.BadRequestFromString("malformed SNS signature")
+	}
+
+	stringToSign, err := canonicalString(message)
+	if err != nil {
+		return failure.BadRequest(err)
+	}
+
+	certificate, err := v.certificate(message.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	publicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return failure.BadRequestFromString("SNS signing certificate has no RSA key")
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(stringToSign))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(stringToSign))
+		digest = sum[:]
+	}
+
+	if err := rsa.VerifyPKCS1v15(publicKey, hash, digest, signature); err != nil {
+		return failure.BadRequestFromString("invalid SNS signature")
+	}
+
+	return nil
+}
+
+// canonicalString builds the string SNS signs for a message of its type.
+func canonicalString(message model.SNSMessage) (string, error) {
+	fields := make([][2]string, 0, 7)
+
+	switch message.Type {
+	case "Notification":
+		fields = append(fields,
+			[2]string{"Message", message.Message},
+			[2]string{"MessageId", message.MessageID.String()})
+		if message.Subject != "" {
+			fields = append(fields, [2]string{"Subject", message.Subject})
+		}
+		fields = append(fields,
+			[2]string{"Timestamp", message.Timestamp},
+			[2]string{"TopicArn", message.TopicARN},
+			[2]string{"Type", message.Type})
+	case "SubscriptionConfirmation", "UnsubscribeConfirmation":
+		fields = append(fields,
+			[2]string{"Message", message.Message},
+			[2]string{"MessageId", message.MessageID.String()},
+			[2]string{"SubscribeURL", message.SubscribeURL},
+			[2]string{"Timestamp", message.Timestamp},
+			[2]string{"Token", message.Token},
+			[2]string{"TopicArn", message.TopicARN},
+			[2]string{"Type", message.Type})
+	default:
+		return "", fmt.Errorf("unknown SNS message type %q", message.Type)
+	}
+
+	var builder strings.Builder
+	for _, field := range fields {
+		builder.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return builder.String(), nil
+}
+
+// certificate returns the signing certificate at rawURL, from the cache if
+// it is still valid.
+func (v *Verifier) certificate(rawURL string) (*x509.Certificate, error) {
+	certificateURL, err := url.Parse(rawURL)
+	if err != nil || certificateURL.Scheme != "https" || !strings.HasSuffix(certificateURL.Path, ".pem") {
+		return nil, failure.BadRequestFromString(fmt.Sprintf("invalid SNS signing certificate URL %q", rawURL))
+	}
+
+	if !v.isAllowed(certificateURL) {
+		return nil, failure.BadRequestFromString(fmt.Sprintf("SNS signing certificate host %q is not allowed", certificateURL.Host))
+	}
+
+	certificate, err := v.cachedOrFetch(rawURL)
+	if err != nil {
+		return nil, failure.InternalError(err)
+	}
+
+	now := time.Now()
+	if now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+		return nil, failure.BadRequestFromString("SNS signing certificate is not valid now")
+	}
+
+	return certificate, nil
+}
+
+// cachedOrFetch returns the certificate at rawURL from the cache, or fetches
+// it. The lock is not held while fetching, so a slow download only delays
+// the verifications waiting for the same certificate.
+func (v *Verifier) cachedOrFetch(rawURL string) (*x509.Certificate, error) {
+	v.mu.Lock()
+	if certificate, ok := v.certificates[rawURL]; ok && time.Now().Before(certificate.NotAfter) {
+		v.mu.Unlock()
+		return certificate, nil
+	}
+
+	if fetch, ok := v.fetches[rawURL]; ok {
+		v.mu.Unlock()
+		<-fetch.done
+		return fetch.certificate, fetch.err
+	}
+
+	fetch := &certificateFetch{done: make(chan struct{})}
+	if v.fetches == nil {
+		v.fetches = make(map[string]*certificateFetch)
+	}
+	v.fetches[rawURL] = fetch
+	v.mu.Unlock()
+
+	fetch.certificate, fetch.err = v.fetch(rawURL)
+
+	v.mu.Lock()
+	delete(v.fetches, rawURL)
+	if fetch.err == nil {
+		if v.certificates == nil {
+			v.certificates = make(map[string]*x509.Certificate)
+		}
+		v.certificates[rawURL] = fetch.certificate
+	}
+	v.mu.Unlock()
+	close(fetch.done)
+
+	return fetch.certificate, fetch.err
+}
+
+func (v *Verifier) isAllowed(certificateURL *url.URL) bool {
+	if len(v.AllowedHosts) == 0 {
+		return certificateURL.Port() == "" && defaultCertificateHost.MatchString(certificateURL.Hostname())
+	}
+
+	for _, host := range v.AllowedHosts {
+		if strings.EqualFold(host, certificateURL.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Verifier) fetch(rawURL string) (*x509.Certificate, error) {
+	response, err := v.Client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching SNS signing certificate: status %d", response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxCertificateSize))
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("SNS signing certificate is not PEM encoded")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
@@ -0,0 +1,169 @@
+package consumer_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// signer signs SNS messages with a locally generated certificate, served
+// over HTTPS like SNS serves its own.
+type signer struct {
+	key     *rsa.PrivateKey
+	server  *httptest.Server
+	fetches int32
+}
+
+func newSigner(t *testing.T) *signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	s := &signer{key: key}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+		w.Write(certificate)
+	}))
+	return s
+}
+
+func (s *signer) verifier() *consumer.Verifier {
+	return &consumer.Verifier{
+		Enabled:      true,
+		AllowedHosts: []string{strings.TrimPrefix(s.server.URL, "https://")},
+		Client:       s.server.Client(),
+	}
+}
+
+// sign signs a notification the way SNS does.
+func (s *signer) sign(t *testing.T, message model.SNSMessage, version string) model.SNSMessage {
+	message.SignatureVersion = version
+	message.SigningCertURL = s.server.URL + "/SimpleNotificationService-test.pem"
+
+	stringToSign := "Message\n" + message.Message + "\n" +
+		"MessageId\n" + message.MessageID.String() + "\n"
+	if message.Subject != "" {
+		stringToSign += "Subject\n" + message.Subject + "\n"
+	}
+	stringToSign += "Timestamp\n" + message.Timestamp + "\n" +
+		"TopicArn\n" + message.TopicARN + "\n" +
+		"Type\n" + message.Type + "\n"
+
+	hash, digest := crypto.SHA256, sha256.Sum256([]byte(stringToSign))
+	signed := digest[:]
+	if version == "1" {
+		sum := sha1.Sum([]byte(stringToSign))
+		hash, signed = crypto.SHA1, sum[:]
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, hash, signed)
+	assert.NoError(t, err)
+	message.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	return message
+}
+
+func notification() model.SNSMessage {
+	return model.SNSMessage{
+		Type:      "Notification",
+		MessageID: uuid.Must(uuid.NewV4()),
+		TopicARN:  "arn:aws:sns:ap-southeast-1:000000000000:foo-created",
+		Subject:   "foo",
+		Message:   `{"name":"foo"}`,
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	s := newSigner(t)
+	defer s.server.Close()
+
+	t.Run("Accepts both signature versions and caches the certificate", func(t *testing.T) {
+		verifier := s.verifier()
+
+		assert.NoError(t, verifier.Verify(s.sign(t, notification(), "1")))
+		assert.NoError(t, verifier.Verify(s.sign(t, notification(), "2")))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&s.fetches))
+	})
+
+	t.Run("Fetches a certificate once for concurrent verifications", func(t *testing.T) {
+		atomic.StoreInt32(&s.fetches, 0)
+		verifier := s.verifier()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			message := s.sign(t, notification(), "2")
+			wg.Add(1)
+			go func(message model.SNSMessage) {
+				defer wg.Done()
+				assert.NoError(t, verifier.Verify(message))
+			}(message)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&s.fetches))
+	})
+
+	t.Run("Rejects tampered messages", func(t *testing.T) {
+		message := s.sign(t, notification(), "2")
+		message.Message = `{"name":"bar"}`
+
+		assert.True(t, consumer.IsPermanent(s.verifier().Verify(message)))
+	})
+
+	t.Run("Rejects certificates from hosts not allowed", func(t *testing.T) {
+		verifier := s.verifier()
+		verifier.AllowedHosts = nil
+
+		assert.True(t, consumer.IsPermanent(verifier.Verify(s.sign(t, notification(), "2"))))
+	})
+
+	t.Run("Rejects unsigned messages", func(t *testing.T) {
+		assert.True(t, consumer.IsPermanent(s.verifier().Verify(notification())))
+	})
+
+	t.Run("Only passes verified messages on", func(t *testing.T) {
+		processed := 0
+		process := consumer.Verified(s.verifier(), func(ctx context.Context, value []byte) error {
+			processed++
+			return nil
+		})
+
+		signed, _ := json.Marshal(s.sign(t, notification(), "2"))
+		unsigned, _ := json.Marshal(notification())
+
+		assert.NoError(t, process(context.Background(), signed))
+		assert.Error(t, process(context.Background(), unsigned))
+		assert.Equal(t, 1, processed)
+	})
+}
@@ -1,18 +1,23 @@
 package consumer
 
 import (
+	"context"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/rs/zerolog/log"
 )
 
-// Process represents the processing function of the message consumer.
-type Process func(e []byte) error
+// Process represents the processing function of the message consumer. ctx
+// carries the trace of the message.
+type Process func(ctx context.Context, e []byte) error
 
 // SQSConfig represents an SQS configuration object.
 type SQSConfig struct {
@@ -34,18 +39,34 @@ func (m *SQSConfig) Retrieve() (credentials.Value, error) {
 
 func createSQSConfig(config *configs.Config) (*session.Session, error) {
 	sqsConfig := SQSConfig{Config: *config}
-	return session.NewSession(&aws.Config{
-		Region:      &config.Event.Consumer.SQS.Region,
-		Credentials: credentials.NewCredentials(&sqsConfig),
-		MaxRetries:  aws.Int(config.Event.Consumer.SQS.MaxRetries),
-	})
+	awsConfig := &aws.Config{
+		Region:           &config.Event.Consumer.SQS.Region,
+		Credentials:      credentials.NewCredentials(&sqsConfig),
+		MaxRetries:       aws.Int(config.Event.Consumer.SQS.MaxRetries),
+		DisableSSL:       aws.Bool(config.Event.Consumer.SQS.DisableSSL),
+		S3ForcePathStyle: aws.Bool(config.Event.Consumer.SQS.ForcePathStyle),
+	}
+
+	// point the client at a local emulator instead of AWS
+	if config.Event.Consumer.SQS.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Event.Consumer.SQS.Endpoint)
+	}
+
+	return session.NewSession(awsConfig)
 }
 
-// SQSConsumer represents an SQS consumer.
+// SQSConsumer represents an SQS consumer. Messages are processed by a bounded
+// pool of workers; messages sharing a MessageGroupId always go to the same
+// worker, so FIFO queues keep their order. A message is deleted once it is
+// processed. When processing fails with a retryable error, the message is
+// redelivered after a backoff based on how often it was received. Messages
+// that fail permanently or too often are moved to DeadLetterURL, if set.
 type SQSConsumer struct {
-	Process Process
-	config  *configs.Config
-	sqs     *sqs.SQS
+	Process       Process
+	DeadLetterURL string
+	config        *configs.Config
+	sqs           sqsiface.SQSAPI
+	lifecycle
 }
 
 // NewSQSConsumer create object Consumer
@@ -54,23 +75,54 @@ func NewSQSConsumer(config *configs.Config) *SQSConsumer {
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed creating sqs config")
 	}
-	return &SQSConsumer{config: config, sqs: sqs.New(sess)}
+	return newSQSConsumer(config, sqs.New(sess))
 }
 
-// Listen is a function to listen new message from sqs queue
+func newSQSConsumer(config *configs.Config, client sqsiface.SQSAPI) *SQSConsumer {
+	return &SQSConsumer{
+		config:    config,
+		sqs:       client,
+		lifecycle: newLifecycle(),
+	}
+}
+
+// Listen is a function to listen new message from sqs queue. It blocks until
+// the consumer is stopped or fails permanently, and the messages in flight
+// are processed.
 func (p *SQSConsumer) Listen(url string) {
-	log.Info().Str("url", url).Msg("SQS Consumer will start polling.")
+	if !p.begin() {
+		return
+	}
+	defer p.end()
+
+	log.Info().Str("url", url).Int("workers", p.workers()).Msg("SQS Consumer will start polling.")
+
+	pool := newWorkerPool(p, url)
+	defer pool.close()
 
 	retries := 0
+	batch := 0
 	for {
-		receiveResp, err := p.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
+		receiveResp, err := p.sqs.ReceiveMessageWithContext(p.ctx, &sqs.ReceiveMessageInput{
 			QueueUrl:            aws.String(url),
 			MaxNumberOfMessages: aws.Int64(p.config.Event.Consumer.SQS.MaxMessage),
+			VisibilityTimeout:   aws.Int64(int64(p.visibilityTimeout() / time.Second)),
 			WaitTimeSeconds:     aws.Int64(p.config.Event.Consumer.SQS.WaitTimeSeconds),
+			AttributeNames: aws.StringSlice([]string{
+				sqs.MessageSystemAttributeNameApproximateReceiveCount,
+				sqs.MessageSystemAttributeNameMessageGroupId,
+			}),
 		})
+		if p.ctx.Err() != nil {
+			p.setStatus(StatusStopped, nil)
+			log.Info().Str("url", url).Msg("SQS Consumer stopped polling.")
+			return
+		}
+
 		if err != nil {
 			if retries == p.config.Event.Consumer.SQS.MaxRetriesConsume {
 				log.Error().Err(err).Int("retries", retries).Msg("failed receiving message after maximum retries, failing permanently")
+				p.setStatus(StatusFailed, err)
 				return
 			}
 
@@ -82,23 +134,150 @@ func (p *SQSConsumer) Listen(url string) {
 				Int("backoffSeconds", p.config.Event.Consumer.SQS.BackoffSeconds).
 				Msg("failed receiving message, will retry")
 			retries++
-			time.Sleep(time.Duration(p.config.Event.Consumer.SQS.BackoffSeconds) * time.Second)
+			select {
+			case <-p.ctx.Done():
+			case <-time.After(time.Duration(p.config.Event.Consumer.SQS.BackoffSeconds) * time.Second):
+			}
 			continue
 		} else {
 			retries = 0
+			p.setStatus(StatusRunning, nil)
 		}
 
-		for _, message := range receiveResp.Messages {
-			err := p.Process([]byte(*message.Body))
-			if err != nil {
-				log.Error().Err(err).Msg("failed processing message")
-			}
+		// messages already received are processed even when stopping, so
+		// none is left waiting for its visibility timeout
+		batch++
+		pool.dispatch(batch, receiveResp.Messages)
+	}
+}
 
-			err = p.deleteMessage(message, url)
-			if err != nil {
-				log.Error().Err(err).Msg("failed deleting message")
+// handle processes a single message and settles it unless it succeeded: moves
+// it to the dead-letter queue when it cannot succeed, or delays its
+// redelivery otherwise. Successfully processed messages are left for the
+// caller to delete in batches. Messages are processed within a span that
+// continues the trace they were published in. StopHeartbeat is called once
+// processing ends, before the message is settled.
+func (p *SQSConsumer) handle(message *sqs.Message, url string, stopHeartbeat func()) (processed bool) {
+	body := []byte(*message.Body)
+	ctx, span := StartSpan(context.Background(), "aws_sqs", url, body)
+
+	err := p.Process(ctx, body)
+	stopHeartbeat()
+
+	tracing.End(span, err)
+
+	if err == nil {
+		return true
+	}
+
+	receiveCount := receiveCount(message)
+	logMsg := log.Error().
+		Err(err).
+		Str("messageId", aws.StringValue(message.MessageId)).
+		Int("receiveCount", receiveCount)
+
+	if IsPermanent(err) || receiveCount >= p.maxReceiveCount() {
+		logMsg.Bool("permanent", IsPermanent(err)).Msg("failed processing message, moving to dead-letter queue")
+		p.deadLetter(message, url, err)
+		return false
+	}
+
+	backoff := visibilityBackoff(p.visibilityBackoff(), receiveCount)
+	logMsg.Dur("backoff", backoff).Msg("failed processing message, will retry")
+	p.changeVisibility(message, url, backoff)
+
+	return false
+}
+
+// heartbeat keeps extending the visibility timeout of a message while it is
+// being processed, so long-running handlers do not see it redelivered. The
+// returned function stops the heartbeat.
+func (p *SQSConsumer) heartbeat(message *sqs.Message, url string) (stop func()) {
+	timeout := p.visibilityTimeout()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.changeVisibility(message, url, timeout)
+			case <-done:
+				return
 			}
 		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (p *SQSConsumer) changeVisibility(message *sqs.Message, url string, timeout time.Duration) {
+	_, err := p.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &url,
+		ReceiptHandle:     message.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(timeout / time.Second)),
+	})
+	if err != nil {
+		// the message is still redelivered once its current visibility
+		// timeout runs out
+		log.Error().Err(err).Msg("failed changing message visibility")
+	}
+}
+
+// deadLetter moves a message to the dead-letter queue. Without one, the
+// message is dropped if the error is permanent, and otherwise left for the
+// queue's own redrive policy.
+func (p *SQSConsumer) deadLetter(message *sqs.Message, url string, cause error) {
+	if p.DeadLetterURL == "" {
+		if !IsPermanent(cause) {
+			log.Warn().Str("messageId", aws.StringValue(message.MessageId)).Msg("no dead-letter queue configured, leaving message in queue")
+			return
+		}
+
+		log.Warn().Str("messageId", aws.StringValue(message.MessageId)).Msg("no dead-letter queue configured, dropping message")
+		if err := p.deleteMessage(message, url); err != nil {
+			log.Error().Err(err).Msg("failed deleting message")
+		}
+		return
+	}
+
+	input := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.DeadLetterURL),
+		MessageBody: message.Body,
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"error": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(cause.Error()),
+			},
+			"sourceQueueUrl": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(url),
+			},
+		},
+	}
+
+	// FIFO dead-letter queues need a group and a deduplication ID
+	if groupID, ok := message.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]; ok {
+		input.MessageGroupId = groupID
+		input.MessageDeduplicationId = message.MessageId
+	}
+
+	if _, err := p.sqs.SendMessage(input); err != nil {
+		// keep the message so it is not lost; it is retried once visible
+		log.Error().Err(err).Msg("failed sending message to dead-letter queue")
+		return
+	}
+
+	if err := p.deleteMessage(message, url); err != nil {
+		log.Error().Err(err).Msg("failed deleting message")
 	}
 }
 
@@ -113,3 +292,38 @@ func (p *SQSConsumer) deleteMessage(msg *sqs.Message, url string) error {
 	}
 	return nil
 }
+
+func (p *SQSConsumer) maxReceiveCount() int {
+	return maxReceiveCount(p.config)
+}
+
+func (p *SQSConsumer) visibilityBackoff() time.Duration {
+	if p.config.Event.Consumer.SQS.VisibilityBackoffSeconds <= 0 {
+		return defaultVisibilityBackoffSeconds * time.Second
+	}
+	return time.Duration(p.config.Event.Consumer.SQS.VisibilityBackoffSeconds) * time.Second
+}
+
+func (p *SQSConsumer) visibilityTimeout() time.Duration {
+	if p.config.Event.Consumer.SQS.VisibilityTimeoutSeconds <= 0 {
+		return defaultVisibilityTimeoutSeconds * time.Second
+	}
+	return time.Duration(p.config.Event.Consumer.SQS.VisibilityTimeoutSeconds) * time.Second
+}
+
+func (p *SQSConsumer) workers() int {
+	if p.config.Event.Consumer.SQS.Workers <= 0 {
+		return defaultWorkers
+	}
+	return p.config.Event.Consumer.SQS.Workers
+}
+
+// receiveCount returns how many times a message has been received, including
+// this time.
+func receiveCount(message *sqs.Message) int {
+	count, err := strconv.Atoi(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameApproximateReceiveCount]))
+	if err != nil {
+		return 1
+	}
+	return count
+}
@@ -0,0 +1,204 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQS delivers a single batch of messages, then long polls until the
+// consumer stops.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+
+	mu         sync.Mutex
+	batch      []*sqs.Message
+	deleted    []string
+	visibility map[string]int64
+	deadLetter []string
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	batch := f.batch
+	f.batch = nil
+	f.mu.Unlock()
+
+	if len(batch) > 0 {
+		return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+	}
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (f *fakeSQS) DeleteMessageBatch(input *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, entry := range input.Entries {
+		f.deleted = append(f.deleted, aws.StringValue(entry.ReceiptHandle))
+	}
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(input *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deleted = append(f.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(input *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.visibility[aws.StringValue(input.ReceiptHandle)] = aws.Int64Value(input.VisibilityTimeout)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeSQS) SendMessage(input *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deadLetter = append(f.deadLetter, aws.StringValue(input.MessageBody))
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func newMessage(body string, groupID string, receiveCount int) *sqs.Message {
+	attributes := map[string]*string{
+		sqs.MessageSystemAttributeNameApproximateReceiveCount: aws.String(strconv.Itoa(receiveCount)),
+	}
+	if groupID != "" {
+		attributes[sqs.MessageSystemAttributeNameMessageGroupId] = aws.String(groupID)
+	}
+
+	return &sqs.Message{
+		MessageId:     aws.String(body),
+		ReceiptHandle: aws.String(body),
+		Body:          aws.String(body),
+		Attributes:    attributes,
+	}
+}
+
+func runConsumer(t *testing.T, client *fakeSQS, process Process, deadLetterURL string) {
+	config := &configs.Config{}
+	config.Event.Consumer.SQS.MaxMessage = 10
+	config.Event.Consumer.SQS.Workers = 4
+	config.Event.Consumer.SQS.MaxReceiveCount = 3
+
+	consumer := newSQSConsumer(config, client)
+	consumer.Process = process
+	consumer.DeadLetterURL = deadLetterURL
+
+	go consumer.Listen("queue")
+	assert.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return client.batch == nil
+	}, time.Second, time.Millisecond)
+	consumer.Stop()
+}
+
+func TestSQSConsumer(t *testing.T) {
+	t.Run("Processes a group in order and deletes in batches", func(t *testing.T) {
+		client := &fakeSQS{visibility: make(map[string]int64)}
+		for i := 0; i < 5; i++ {
+			client.batch = append(client.batch, newMessage("a"+strconv.Itoa(i), "a", 1))
+		}
+		client.batch = append(client.batch, newMessage("b0", "", 1))
+
+		var mu sync.Mutex
+		processed := make([]string, 0)
+		runConsumer(t, client, func(ctx context.Context, body []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if string(body) != "b0" {
+				processed = append(processed, string(body))
+			}
+			return nil
+		}, "")
+
+		assert.Equal(t, []string{"a0", "a1", "a2", "a3", "a4"}, processed)
+		assert.ElementsMatch(t, []string{"a0", "a1", "a2", "a3", "a4", "b0"}, client.deleted)
+	})
+
+	t.Run("Holds back a group after a retryable failure", func(t *testing.T) {
+		client := &fakeSQS{visibility: make(map[string]int64)}
+		client.batch = []*sqs.Message{newMessage("a0", "a", 1), newMessage("a1", "a", 1)}
+
+		runConsumer(t, client, func(ctx context.Context, body []byte) error {
+			return errors.New("database down")
+		}, "dlq")
+
+		assert.Empty(t, client.deleted)
+		assert.Equal(t, int64(30), client.visibility["a0"])
+		assert.Equal(t, int64(0), client.visibility["a1"])
+		assert.Empty(t, client.deadLetter)
+	})
+
+	t.Run("Extends the visibility of messages queued behind a slow one", func(t *testing.T) {
+		client := &fakeSQS{visibility: make(map[string]int64)}
+		client.batch = []*sqs.Message{newMessage("a0", "a", 1), newMessage("a1", "a", 1), newMessage("a2", "a", 1)}
+
+		config := &configs.Config{}
+		config.Event.Consumer.SQS.MaxMessage = 10
+		config.Event.Consumer.SQS.Workers = 1
+		config.Event.Consumer.SQS.VisibilityTimeoutSeconds = 1
+
+		// visibility of each message when its processing starts
+		extended := make(map[string]int64)
+		consumer := newSQSConsumer(config, client)
+		consumer.Process = func(ctx context.Context, body []byte) error {
+			client.mu.Lock()
+			visibility, ok := client.visibility[string(body)]
+			client.mu.Unlock()
+			if ok {
+				extended[string(body)] = visibility
+			}
+
+			if string(body) == "a0" {
+				time.Sleep(1200 * time.Millisecond)
+			}
+			return nil
+		}
+
+		go consumer.Listen("queue")
+		assert.Eventually(t, func() bool {
+			client.mu.Lock()
+			defer client.mu.Unlock()
+			return client.batch == nil
+		}, time.Second, time.Millisecond)
+		consumer.Stop()
+
+		assert.Equal(t, map[string]int64{"a1": 1, "a2": 1}, extended)
+		assert.ElementsMatch(t, []string{"a0", "a1", "a2"}, client.deleted)
+	})
+
+	t.Run("Dead-letters permanent failures and exhausted retries", func(t *testing.T) {
+		client := &fakeSQS{visibility: make(map[string]int64)}
+		client.batch = []*sqs.Message{newMessage("malformed", "", 1), newMessage("exhausted", "", 3)}
+
+		runConsumer(t, client, func(ctx context.Context, body []byte) error {
+			if string(body) == "malformed" {
+				return failure.BadRequestFromString("malformed")
+			}
+			return errors.New("database down")
+		}, "dlq")
+
+		assert.ElementsMatch(t, []string{"malformed", "exhausted"}, client.deadLetter)
+		assert.ElementsMatch(t, []string{"malformed", "exhausted"}, client.deleted)
+	})
+}
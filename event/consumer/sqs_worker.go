@@ -0,0 +1,196 @@
+package consumer
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultWorkers                  = 1
+	defaultVisibilityTimeoutSeconds = 30
+	// maxDeleteBatchSize is the most entries SQS accepts in one batch delete.
+	maxDeleteBatchSize  = 10
+	deleteFlushInterval = time.Second
+)
+
+// job is a received message waiting for a worker. Batch numbers the receive
+// call the message came from. StopHeartbeat stops extending the visibility
+// timeout of the message, which runs from dispatch until the message is
+// settled.
+type job struct {
+	message       *sqs.Message
+	batch         int
+	stopHeartbeat func()
+}
+
+// workerPool processes the messages of a single queue with a fixed number of
+// workers, and deletes processed messages in batches.
+type workerPool struct {
+	consumer *SQSConsumer
+	url      string
+	jobs     []chan job
+	next     int
+	deletes  chan *sqs.Message
+	workers  sync.WaitGroup
+	deleter  sync.WaitGroup
+}
+
+func newWorkerPool(consumer *SQSConsumer, url string) *workerPool {
+	pool := &workerPool{
+		consumer: consumer,
+		url:      url,
+		jobs:     make([]chan job, consumer.workers()),
+		deletes:  make(chan *sqs.Message, maxDeleteBatchSize),
+	}
+
+	for i := range pool.jobs {
+		pool.jobs[i] = make(chan job)
+		pool.workers.Add(1)
+		go pool.work(pool.jobs[i])
+	}
+
+	pool.deleter.Add(1)
+	go pool.deleteInBatches()
+
+	return pool
+}
+
+// dispatch hands the messages of a received batch to the workers, blocking
+// until each message is taken by its worker. Messages of the same group
+// always go to the same worker so they are processed in the order they were
+// received. The visibility timeout of every message is extended from the
+// start, since a message may wait behind a slow one for longer than it.
+func (w *workerPool) dispatch(batch int, messages []*sqs.Message) {
+	jobs := make([]job, 0, len(messages))
+	for _, message := range messages {
+		jobs = append(jobs, job{
+			message:       message,
+			batch:         batch,
+			stopHeartbeat: w.consumer.heartbeat(message, w.url),
+		})
+	}
+
+	for _, j := range jobs {
+		index := w.next
+		if groupID, ok := j.message.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]; ok {
+			hash := fnv.New32a()
+			hash.Write([]byte(aws.StringValue(groupID)))
+			index = int(hash.Sum32() % uint32(len(w.jobs)))
+		} else {
+			w.next = (w.next + 1) % len(w.jobs)
+		}
+
+		w.jobs[index] <- j
+	}
+}
+
+// close waits for every dispatched message to be processed and deleted.
+func (w *workerPool) close() {
+	for _, jobs := range w.jobs {
+		close(jobs)
+	}
+	w.workers.Wait()
+
+	close(w.deletes)
+	w.deleter.Wait()
+}
+
+func (w *workerPool) work(jobs chan job) {
+	defer w.workers.Done()
+
+	// failed maps a message group to the batch in which one of its messages
+	// failed; the group's later messages in that batch must not overtake it
+	failed := make(map[string]int)
+
+	for j := range jobs {
+		groupID, grouped := j.message.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]
+		group := aws.StringValue(groupID)
+
+		if grouped {
+			if batch, ok := failed[group]; ok && batch == j.batch {
+				// make it visible again so it is redelivered after the
+				// failed message
+				j.stopHeartbeat()
+				w.consumer.changeVisibility(j.message, w.url, 0)
+				continue
+			}
+		}
+
+		if w.consumer.handle(j.message, w.url, j.stopHeartbeat) {
+			w.deletes <- j.message
+			continue
+		}
+
+		if grouped {
+			failed[group] = j.batch
+		}
+	}
+}
+
+// deleteInBatches deletes processed messages with as few calls as possible,
+// flushing whenever a batch is full or has waited long enough.
+func (w *workerPool) deleteInBatches() {
+	defer w.deleter.Done()
+
+	ticker := time.NewTicker(deleteFlushInterval)
+	defer ticker.Stop()
+
+	pending := make([]*sqs.Message, 0, maxDeleteBatchSize)
+	for {
+		select {
+		case message, ok := <-w.deletes:
+			if !ok {
+				w.deleteBatch(pending)
+				return
+			}
+
+			pending = append(pending, message)
+			if len(pending) == maxDeleteBatchSize {
+				w.deleteBatch(pending)
+				pending = pending[:0]
+			}
+		case <-ticker.C:
+			w.deleteBatch(pending)
+			pending = pending[:0]
+		}
+	}
+}
+
+func (w *workerPool) deleteBatch(messages []*sqs.Message) {
+	if len(messages) == 0 {
+		return
+	}
+
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, len(messages))
+	for i, message := range messages {
+		entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            aws.String(strconv.Itoa(i)),
+			ReceiptHandle: message.ReceiptHandle,
+		})
+	}
+
+	output, err := w.consumer.sqs.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(w.url),
+		Entries:  entries,
+	})
+	if err != nil {
+		// processed messages that are not deleted are redelivered, so
+		// processing must be idempotent
+		log.Error().Err(err).Int("count", len(entries)).Msg("failed deleting messages")
+		return
+	}
+
+	for _, failed := range output.Failed {
+		log.Error().
+			Str("id", aws.StringValue(failed.Id)).
+			Str("code", aws.StringValue(failed.Code)).
+			Str("reason", aws.StringValue(failed.Message)).
+			Msg("failed deleting message")
+	}
+}
@@ -0,0 +1,24 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSpan starts the span of processing a message received from source,
+// e.g. a queue URL, continuing the trace it was published in. The trace
+// context travels in the message attributes of the SNS envelope in body.
+func StartSpan(ctx context.Context, system string, source string, body []byte) (context.Context, trace.Span) {
+	snsMessage := model.SNSMessage{}
+	if err := json.Unmarshal(body, &snsMessage); err == nil {
+		ctx = tracing.Extract(ctx, snsMessage.Attributes())
+	}
+
+	return tracing.Start(ctx, source+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		tracing.MessagingAttributes(system, source))
+}
@@ -1,8 +1,8 @@
 package foobarbaz
 
 import (
+	"context"
 	"encoding/json"
-	"net/http"
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/consumer"
@@ -10,25 +10,34 @@ import (
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
-	"github.com/rs/zerolog/log"
+	"github.com/gofrs/uuid"
 )
 
-// ConsumerImpl is the SQS consumer implementation for this domain.
+// ConsumerImpl is the event consumer implementation for this domain.
+// Process processes a message however it is delivered: polled from SQS by
+// Consumer, or pushed by SNS over HTTP.
 type ConsumerImpl struct {
 	Config   *configs.Config
 	Service  foobarbaz.FooService
 	Consumer consumer.Consumer
+	Process  consumer.Process
 }
 
 // ProvideConsumerImpl is the provider for this consumer.
-func ProvideConsumerImpl(config *configs.Config, service foobarbaz.FooService) ConsumerImpl {
+func ProvideConsumerImpl(config *configs.Config, service foobarbaz.FooService, ledger consumer.Ledger, verifier *consumer.Verifier) ConsumerImpl {
 	c := ConsumerImpl{}
 	c.Config = config
 	c.Service = service
 
-	sqsConsumer := consumer.NewSQSConsumer(config)
-	sqsConsumer.Process = c.processEvent
-	c.Consumer = sqsConsumer
+	// events published before the switch to CloudEvents hold only the data
+	router := consumer.NewRouter(foobarbaz.FooBarBazEventType).
+		Handle(foobarbaz.FooBarBazEventType, c.handleFooBarBaz)
+
+	c.Process = consumer.Idempotent("foobarbaz", ledger, config, router.Process)
+	c.Consumer = consumer.NewConsumer(
+		config,
+		consumer.Verified(verifier, c.Process),
+		config.Event.Consumer.SQS.Topics.FooBarBaz.DeadLetterURL)
 
 	return c
 }
@@ -40,46 +49,76 @@ func (c *ConsumerImpl) Start() {
 	}
 }
 
-func (c *ConsumerImpl) processEvent(value []byte) (err error) {
-	snsMessage := model.SNSMessage{}
-	err = json.Unmarshal(value, &snsMessage)
-	if err != nil {
-		logger.ErrorWithStack(err)
-		return
-	}
+// Stop stops the SQS subscriber once the messages in flight are processed.
+func (c *ConsumerImpl) Stop() {
+	c.Consumer.Stop()
+}
+
+// Subscription returns the SNS topic whose messages are pushed to this
+// consumer, if any.
+func (c *ConsumerImpl) Subscription() (topicARN string) {
+	return c.Config.Event.Consumer.Push.Topics.FooBarBaz.ARN
+}
+
+// Health returns the state of the SQS subscriber.
+func (c *ConsumerImpl) Health() consumer.Health {
+	return c.Consumer.Health()
+}
 
-	log.
+func (c *ConsumerImpl) handleFooBarBaz(ctx context.Context, event model.CloudEvent) (err error) {
+	logger.FromContext(ctx).
 		Info().
-		Str("topicARN", snsMessage.TopicARN).
-		Interface("value", snsMessage).
-		Msg("Received SNS message")
+		Str("id", event.ID).
+		Str("source", event.Source).
+		Str("type", event.Type).
+		Msg("Received event")
 
-	requestFormat := foobarbaz.FooRequestFormat{}
-	err = json.Unmarshal([]byte(snsMessage.Message), &requestFormat)
+	requestFormat, err := fooRequestFormat(event)
 	if err != nil {
-		logger.ErrorWithStack(err)
-		return
+		logger.ErrorWithStackContext(ctx, err)
+		return failure.BadRequest(err)
 	}
 
-	_, err = c.Service.Create(requestFormat, snsMessage.MessageID)
+	// 4xx failures are permanent and move the message to the dead-letter
+	// queue; anything else is retried
+	_, err = c.Service.Create(ctx, requestFormat, uuid.FromStringOrNil(event.ID))
 	if err != nil {
-		err = c.checkError(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (c *ConsumerImpl) checkError(err error) error {
-	f, ok := err.(*failure.Failure)
-	if ok {
-		if f.Code == http.StatusBadRequest {
-			err = nil
-		}
+// fooRequestFormat reads the request to create a Foo from any version of the
+// event: version 1 carries the request itself, version 2 the created Foo.
+func fooRequestFormat(event model.CloudEvent) (requestFormat foobarbaz.FooRequestFormat, err error) {
+	if event.SchemaVersion < 2 {
+		err = json.Unmarshal(event.Data, &requestFormat)
+		return
 	}
 
+	foo := foobarbaz.FooResponseFormat{}
+	err = json.Unmarshal(event.Data, &foo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		return
 	}
 
-	return err
+	requestFormat = foobarbaz.FooRequestFormat{
+		Name:        foo.Name,
+		ShippingFee: foo.ShippingFee,
+		Status:      foo.Status,
+		Items:       make([]foobarbaz.FooItemRequestFormat, 0, len(foo.Items)),
+	}
+	for _, item := range foo.Items {
+		requestFormat.Items = append(requestFormat.Items, foobarbaz.FooItemRequestFormat{
+			ID:          item.ID,
+			SKU:         item.SKU,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Discount:    item.Discount,
+		})
+	}
+
+	return
 }
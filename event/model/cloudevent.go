@@ -0,0 +1,68 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// CloudEventsSpecVersion is the version of the CloudEvents specification
+	// events are published in.
+	CloudEventsSpecVersion = "1.0"
+	// JSONContentType is the content type of event data.
+	JSONContentType = "application/json"
+)
+
+// CloudEvent is an event in the CloudEvents 1.0 JSON format, which is how
+// events leave the process. Data holds the JSON payload as is. SchemaVersion
+// is an extension attribute naming the version of the schema Data matches.
+type CloudEvent struct {
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Subject         string          `json:"subject,omitempty"`
+	SchemaVersion   int             `json:"schemaversion,omitempty"`
+	Time            time.Time       `json:"time"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// ToCloudEvent converts this EventWrapper to a CloudEvent originating from
+// source. Events written before they carried an ID are given a new one.
+func (e EventWrapper) ToCloudEvent(source string) CloudEvent {
+	id := e.ID
+	if id == "" {
+		newID, _ := uuid.NewV4()
+		id = newID.String()
+	}
+
+	return CloudEvent{
+		ID:              id,
+		Source:          source,
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            e.EventType,
+		DataContentType: JSONContentType,
+		Subject:         e.Subject,
+		SchemaVersion:   e.SchemaVersion,
+		Time:            e.Data.Timestamp,
+		Data:            json.RawMessage(e.Data.Value),
+	}
+}
+
+// DecodeCloudEvent decodes a message published as a CloudEvent. It returns
+// false for any other message, e.g. a legacy payload holding only the data.
+func DecodeCloudEvent(message []byte) (event CloudEvent, ok bool, err error) {
+	if json.Unmarshal(message, &event) != nil || event.SpecVersion == "" {
+		return CloudEvent{}, false, nil
+	}
+
+	if event.ID == "" || event.Source == "" || event.Type == "" {
+		return event, true, errors.New("cloud event is missing id, source or type")
+	}
+
+	return event, true, nil
+}
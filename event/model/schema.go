@@ -0,0 +1,112 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// SchemaRegistry maps an event type and a schema version to the JSON Schema
+// its data must match. Versions let a payload evolve: a new version is
+// registered next to the old ones, producers move to it, and subscribers keep
+// accepting the versions still in flight. Event types without a registered
+// schema are not validated.
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]map[int]*gojsonschema.Schema
+	latest  map[string]int
+}
+
+// NewSchemaRegistry creates an empty SchemaRegistry.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas: make(map[string]map[int]*gojsonschema.Schema),
+		latest:  make(map[string]int),
+	}
+}
+
+// Register registers the JSON Schema of a version of an event type. Versions
+// start at 1.
+func (r *SchemaRegistry) Register(eventType string, version int, schema string) error {
+	if version < 1 {
+		return fmt.Errorf("schema version of %s must be at least 1", eventType)
+	}
+
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		return fmt.Errorf("invalid schema for %s v%d: %w", eventType, version, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.schemas[eventType] == nil {
+		r.schemas[eventType] = make(map[int]*gojsonschema.Schema)
+	}
+	r.schemas[eventType][version] = compiled
+	if version > r.latest[eventType] {
+		r.latest[eventType] = version
+	}
+
+	return nil
+}
+
+// MustRegister is like Register but panics on an invalid schema. It is meant
+// for schemas declared in code.
+func (r *SchemaRegistry) MustRegister(eventType string, version int, schema string) *SchemaRegistry {
+	if err := r.Register(eventType, version, schema); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Latest returns the newest schema version of an event type, or 0 if it has
+// no schema.
+func (r *SchemaRegistry) Latest(eventType string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.latest[eventType]
+}
+
+// Validate validates the data of an event against the schema of its version.
+// Version 0 stands for events published before they carried a version, which
+// match version 1.
+func (r *SchemaRegistry) Validate(eventType string, version int, data []byte) error {
+	r.mu.RLock()
+	versions, ok := r.schemas[eventType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	if version == 0 {
+		version = 1
+	}
+
+	schema, ok := versions[version]
+	if !ok {
+		return fmt.Errorf("unknown schema version %d for %s", version, eventType)
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return fmt.Errorf("invalid %s v%d data: %w", eventType, version, err)
+	}
+
+	if !result.Valid() {
+		details := make([]string, 0, len(result.Errors()))
+		for _, resultErr := range result.Errors() {
+			details = append(details, resultErr.String())
+		}
+		return fmt.Errorf("invalid %s v%d data: %s", eventType, version, strings.Join(details, "; "))
+	}
+
+	return nil
+}
+
+// ValidateEvent validates the data of an EventWrapper.
+func (r *SchemaRegistry) ValidateEvent(event EventWrapper) error {
+	return r.Validate(event.EventType, event.SchemaVersion, event.Data.Value)
+}
@@ -0,0 +1,76 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	nameSchema     = `{"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}`
+	fullNameSchema = `{"type": "object", "required": ["firstName", "lastName"]}`
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	registry := model.NewSchemaRegistry().
+		MustRegister("user.created", 1, nameSchema).
+		MustRegister("user.created", 2, fullNameSchema)
+
+	t.Run("Validates against the event's version", func(t *testing.T) {
+		assert.Equal(t, 2, registry.Latest("user.created"))
+		assert.NoError(t, registry.Validate("user.created", 1, []byte(`{"name": "foo"}`)))
+		assert.NoError(t, registry.Validate("user.created", 2, []byte(`{"firstName": "foo", "lastName": "bar"}`)))
+		assert.Error(t, registry.Validate("user.created", 2, []byte(`{"name": "foo"}`)))
+	})
+
+	t.Run("Treats unversioned events as version 1", func(t *testing.T) {
+		assert.NoError(t, registry.Validate("user.created", 0, []byte(`{"name": "foo"}`)))
+		assert.Error(t, registry.Validate("user.created", 0, []byte(`{"name": 1}`)))
+	})
+
+	t.Run("Rejects unknown versions and skips unknown types", func(t *testing.T) {
+		assert.Error(t, registry.Validate("user.created", 3, []byte(`{}`)))
+		assert.NoError(t, registry.Validate("user.deleted", 1, []byte(`"anything"`)))
+		assert.Equal(t, 0, registry.Latest("user.deleted"))
+	})
+
+	t.Run("Rejects invalid schemas", func(t *testing.T) {
+		assert.Error(t, registry.Register("user.updated", 1, `{"type": 1}`))
+		assert.Error(t, registry.Register("user.updated", 0, nameSchema))
+	})
+}
+
+func TestFooBarBazSchemas(t *testing.T) {
+	request := foobarbaz.FooRequestFormat{
+		Name:        "foo",
+		ShippingFee: 10,
+		Status:      foobarbaz.FooStatusNew,
+		Items: []foobarbaz.FooItemRequestFormat{{
+			ID:          uuid.Must(uuid.NewV4()),
+			SKU:         "sku",
+			ProductName: "product",
+			Quantity:    2,
+			UnitPrice:   100,
+			Discount:    5,
+		}},
+	}
+	foo, err := foobarbaz.Foo{}.NewFromRequestFormat(request, uuid.Must(uuid.NewV4()))
+	assert.NoError(t, err)
+
+	t.Run("Publishes the created Foo at version 2", func(t *testing.T) {
+		event := model.NewEvent(foobarbaz.FooBarBazEventType, foo)
+
+		assert.Equal(t, 2, event.SchemaVersion)
+		assert.NoError(t, model.Schemas.ValidateEvent(event))
+	})
+
+	t.Run("Still accepts the request at version 1", func(t *testing.T) {
+		event := model.NewEvent(foobarbaz.FooBarBazEventType, request).WithSchemaVersion(1)
+
+		assert.NoError(t, model.Schemas.ValidateEvent(event))
+		assert.Error(t, model.Schemas.ValidateEvent(event.WithSchemaVersion(2)))
+	})
+}
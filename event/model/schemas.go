@@ -0,0 +1,84 @@
+package model
+
+// FooBarBazEventType is the type of the event published when a Foo is
+// created.
+const FooBarBazEventType = "evm.boilerplate-go.foo-bar-baz.fifo"
+
+// Schemas is the registry of the contracts of the events this service
+// publishes and consumes.
+var Schemas = NewSchemaRegistry().
+	MustRegister(FooBarBazEventType, 1, fooBarBazSchemaV1).
+	MustRegister(FooBarBazEventType, 2, fooBarBazSchemaV2)
+
+// fooBarBazSchemaV1 is the request a Foo was created from.
+const fooBarBazSchemaV1 = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["name", "shippingFee", "status", "items"],
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"shippingFee": {"type": "number", "minimum": 0},
+		"status": {"type": "string", "minLength": 1},
+		"items": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["sku", "productName", "quantity", "unitPrice", "discount"],
+				"properties": {
+					"id": {"type": "string"},
+					"sku": {"type": "string", "minLength": 1},
+					"productName": {"type": "string", "minLength": 1},
+					"quantity": {"type": "integer", "minimum": 1},
+					"unitPrice": {"type": "number", "minimum": 0},
+					"discount": {"type": "number", "minimum": 0}
+				}
+			}
+		}
+	}
+}`
+
+// fooBarBazSchemaV2 is the created Foo, with its ID and totals.
+const fooBarBazSchemaV2 = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": [
+		"id", "name", "totalQuantity", "totalPrice", "totalDiscount", "shippingFee",
+		"grandTotal", "status", "created", "createdBy", "items"
+	],
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"name": {"type": "string", "minLength": 1},
+		"totalQuantity": {"type": "integer", "minimum": 0},
+		"totalPrice": {"type": "number", "minimum": 0},
+		"totalDiscount": {"type": "number", "minimum": 0},
+		"shippingFee": {"type": "number", "minimum": 0},
+		"grandTotal": {"type": "number"},
+		"status": {
+			"type": "string",
+			"enum": ["new", "pending", "verified", "paid", "inTransit", "delivered", "failedToDeliver"]
+		},
+		"created": {"type": "string", "format": "date-time"},
+		"createdBy": {"type": "string", "format": "uuid"},
+		"items": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": [
+					"entityId", "fooId", "sku", "productName", "quantity", "unitPrice",
+					"totalPrice", "discount", "grandTotal"
+				],
+				"properties": {
+					"entityId": {"type": "string", "format": "uuid"},
+					"fooId": {"type": "string", "format": "uuid"},
+					"sku": {"type": "string", "minLength": 1},
+					"productName": {"type": "string", "minLength": 1},
+					"quantity": {"type": "integer", "minimum": 1},
+					"unitPrice": {"type": "number", "minimum": 0},
+					"totalPrice": {"type": "number", "minimum": 0},
+					"discount": {"type": "number", "minimum": 0},
+					"grandTotal": {"type": "number"}
+				}
+			}
+		}
+	}
+}`
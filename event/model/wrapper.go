@@ -8,23 +8,52 @@ import (
 )
 
 // SNSMessage is a wrapper struct for messages received in SQS that originated
-// from SNS.
+// from SNS. Subject is only set on notifications published with one, and
+// SubscribeURL and Token only on subscription confirmations.
+// MessageAttributes holds the attributes the message was published with.
 type SNSMessage struct {
 	Type             string    `json:"Type"`
 	MessageID        uuid.UUID `json:"MessageId"`
 	TopicARN         string    `json:"TopicArn"`
+	Subject          string    `json:"Subject,omitempty"`
 	Message          string    `json:"Message"`
 	Timestamp        string    `json:"Timestamp"`
 	SignatureVersion string    `json:"SignatureVersion"`
 	Signature        string    `json:"Signature"`
 	SigningCertURL   string    `json:"SigningCertURL"`
+	SubscribeURL     string    `json:"SubscribeURL,omitempty"`
+	Token            string    `json:"Token,omitempty"`
 	UnsubscribeURL   string    `json:"UnsubscribeURL"`
+
+	MessageAttributes map[string]SNSMessageAttribute `json:"MessageAttributes,omitempty"`
+}
+
+// SNSMessageAttribute is a message attribute as found in the SNS envelope.
+type SNSMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Attributes returns the string message attributes of this SNSMessage.
+func (m SNSMessage) Attributes() map[string]string {
+	attributes := make(map[string]string, len(m.MessageAttributes))
+	for name, attribute := range m.MessageAttributes {
+		if attribute.Type == "String" {
+			attributes[name] = attribute.Value
+		}
+	}
+	return attributes
 }
 
-// EventWrapper is the wrapper object for events.
+// EventWrapper is the wrapper object for events. Subject optionally names
+// what the event is about, e.g. the ID of the entity it describes.
+// SchemaVersion is the version of the schema in Schemas the data matches.
 type EventWrapper struct {
-	EventType string `json:"event_type"`
-	Data      Data   `json:"data"`
+	ID            string `json:"id"`
+	EventType     string `json:"event_type"`
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+	Data          Data   `json:"data"`
 }
 
 // Data contains the data that is to be sent using an event.
@@ -33,13 +62,17 @@ type Data struct {
 	Value     []byte    `json:"value"`
 }
 
-// NewEvent creates a new event given an event type and an arbitrary model.
-// Returns an EventWrapper object.
+// NewEvent creates a new event given an event type and an arbitrary model,
+// at the latest schema version of the event type. Returns an EventWrapper
+// object.
 func NewEvent(eventType string, model interface{}) EventWrapper {
 	value, _ := json.Marshal(model)
+	id, _ := uuid.NewV4()
 
 	return EventWrapper{
-		EventType: eventType,
+		ID:            id.String(),
+		EventType:     eventType,
+		SchemaVersion: Schemas.Latest(eventType),
 		Data: Data{
 			Timestamp: time.Now(),
 			Value:     value,
@@ -47,10 +80,28 @@ func NewEvent(eventType string, model interface{}) EventWrapper {
 	}
 }
 
+// WithSubject returns a copy of this EventWrapper about subject.
+func (e EventWrapper) WithSubject(subject string) EventWrapper {
+	e.Subject = subject
+	return e
+}
+
+// WithSchemaVersion returns a copy of this EventWrapper whose data matches an
+// older schema version, e.g. while subscribers are migrating.
+func (e EventWrapper) WithSchemaVersion(version int) EventWrapper {
+	e.SchemaVersion = version
+	return e
+}
+
 // PublishRequest is a wrapper for all message publishing requests.
+// Attributes are sent as string message attributes. MessageGroupID and
+// DeduplicationID only apply to FIFO topics; without a DeduplicationID the
+// topic must have content-based deduplication enabled.
 type PublishRequest struct {
-	Channel        string
-	Event          EventWrapper
-	MessageGroupID *string
-	Topic          string
+	Attributes      map[string]string
+	Channel         string
+	DeduplicationID *string
+	Event           EventWrapper
+	MessageGroupID  *string
+	Topic           string
 }
@@ -0,0 +1,240 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	outboxQueries = struct {
+		selectPending string
+		insertMessage string
+		markSent      string
+		markRetry     string
+		markFailed    string
+		acquireLock   string
+		releaseLock   string
+	}{
+		selectPending: `
+			SELECT
+				seq,
+				id,
+				aggregate_type,
+				aggregate_id,
+				topic,
+				event_type,
+				payload,
+				message_group_id,
+				attributes,
+				attempts,
+				last_error,
+				created_at,
+				available_at,
+				sent_at,
+				failed_at
+			FROM outbox_messages
+			WHERE sent_at IS NULL AND failed_at IS NULL AND available_at <= ?
+			AND NOT EXISTS (
+				SELECT 1
+				FROM outbox_messages earlier
+				WHERE earlier.aggregate_type = outbox_messages.aggregate_type
+				AND earlier.aggregate_id = outbox_messages.aggregate_id
+				AND earlier.seq < outbox_messages.seq
+				AND earlier.sent_at IS NULL AND earlier.failed_at IS NULL
+				AND earlier.available_at > ?
+			)
+			ORDER BY seq
+			LIMIT ?
+		`,
+		insertMessage: `
+			INSERT INTO outbox_messages (
+				id,
+				aggregate_type,
+				aggregate_id,
+				topic,
+				event_type,
+				payload,
+				message_group_id,
+				attributes,
+				attempts,
+				last_error,
+				created_at,
+				available_at,
+				sent_at,
+				failed_at
+			) VALUES (
+				:id,
+				:aggregate_type,
+				:aggregate_id,
+				:topic,
+				:event_type,
+				:payload,
+				:message_group_id,
+				:attributes,
+				:attempts,
+				:last_error,
+				:created_at,
+				:available_at,
+				:sent_at,
+				:failed_at
+			)
+		`,
+		markSent: `
+			UPDATE outbox_messages
+			SET
+				attempts = :attempts,
+				sent_at = :sent_at
+			WHERE
+				id = :id
+		`,
+		markRetry: `
+			UPDATE outbox_messages
+			SET
+				attempts = :attempts,
+				last_error = :last_error,
+				available_at = :available_at
+			WHERE
+				id = :id
+		`,
+		markFailed: `
+			UPDATE outbox_messages
+			SET
+				attempts = :attempts,
+				last_error = :last_error,
+				failed_at = :failed_at
+			WHERE
+				id = :id
+		`,
+		acquireLock: `SELECT GET_LOCK(?, 0)`,
+		releaseLock: `DO RELEASE_LOCK(?)`,
+	}
+)
+
+// Message is an event waiting in the outbox to be published. It is written in
+// the same transaction as the change to the aggregate it describes, so the
+// event exists if and only if the change was committed.
+type Message struct {
+	Seq            int64       `db:"seq"`
+	ID             uuid.UUID   `db:"id"`
+	AggregateType  string      `db:"aggregate_type"`
+	AggregateID    string      `db:"aggregate_id"`
+	Topic          string      `db:"topic"`
+	EventType      string      `db:"event_type"`
+	Payload        string      `db:"payload"`
+	MessageGroupID null.String `db:"message_group_id"`
+	Attributes     null.String `db:"attributes"`
+	Attempts       int         `db:"attempts"`
+	LastError      null.String `db:"last_error"`
+	CreatedAt      time.Time   `db:"created_at"`
+	AvailableAt    time.Time   `db:"available_at"`
+	SentAt         null.Time   `db:"sent_at"`
+	FailedAt       null.Time   `db:"failed_at"`
+}
+
+// NewMessage creates an outbox message for a publish request about an
+// aggregate, e.g. a Foo and its ID. Messages of the same aggregate are relayed
+// in the order they were written. The attributes of the request, e.g. its
+// trace context, are kept as JSON.
+func NewMessage(aggregateType string, aggregateID string, request model.PublishRequest) (message Message, err error) {
+	// an event that can never be published must not be committed
+	err = model.Schemas.ValidateEvent(request.Event)
+	if err != nil {
+		return message, failure.InternalError(err)
+	}
+
+	payload, err := json.Marshal(request.Event)
+	if err != nil {
+		return message, failure.InternalError(err)
+	}
+
+	attributes := null.String{}
+	if len(request.Attributes) > 0 {
+		encoded, err := json.Marshal(request.Attributes)
+		if err != nil {
+			return message, failure.InternalError(err)
+		}
+		attributes = null.StringFrom(string(encoded))
+	}
+
+	id, _ := uuid.NewV4()
+	now := time.Now()
+
+	message = Message{
+		ID:             id,
+		AggregateType:  aggregateType,
+		AggregateID:    aggregateID,
+		Topic:          request.Topic,
+		EventType:      request.Event.EventType,
+		Payload:        string(payload),
+		MessageGroupID: null.StringFromPtr(request.MessageGroupID),
+		Attributes:     attributes,
+		CreatedAt:      now,
+		AvailableAt:    now,
+	}
+
+	return
+}
+
+// ToPublishRequest converts this Message back to the request it was created
+// from.
+func (m Message) ToPublishRequest() (request model.PublishRequest, err error) {
+	err = json.Unmarshal([]byte(m.Payload), &request.Event)
+	if err != nil {
+		return
+	}
+
+	if m.Attributes.Valid {
+		err = json.Unmarshal([]byte(m.Attributes.String), &request.Attributes)
+		if err != nil {
+			return
+		}
+	}
+
+	request.Topic = m.Topic
+	request.MessageGroupID = m.MessageGroupID.Ptr()
+	if m.MessageGroupID.Valid {
+		// a message published again after failing to be marked sent is
+		// dropped by FIFO topics instead of being delivered twice
+		deduplicationID := m.ID.String()
+		request.DeduplicationID = &deduplicationID
+	}
+
+	return
+}
+
+// orderingKey identifies the aggregate whose messages must stay in order.
+func (m Message) orderingKey() string {
+	return m.AggregateType + ":" + m.AggregateID
+}
+
+// Write inserts messages into the outbox using the caller's transaction. Call
+// it from within MySQLConn.WithTransaction, next to the domain change.
+func Write(tx *sqlx.Tx, messages ...Message) (err error) {
+	if len(messages) == 0 {
+		return
+	}
+
+	stmt, err := tx.PrepareNamed(outboxQueries.insertMessage)
+	if err != nil {
+		logger.ErrorWithStack(err)
+		return
+	}
+	defer stmt.Close()
+
+	for _, message := range messages {
+		_, err = stmt.Exec(message)
+		if err != nil {
+			logger.ErrorWithStack(err)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,314 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/guregu/null"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultBatchSize            = 100
+	defaultIntervalMilliseconds = 1000
+	defaultMaxAttempts          = 10
+	maxRetryDelay               = 5 * time.Minute
+)
+
+var metrics = struct {
+	published *expvar.Int
+	retried   *expvar.Int
+	failed    *expvar.Int
+}{
+	published: expvar.NewInt("outbox.published"),
+	retried:   expvar.NewInt("outbox.retried"),
+	failed:    expvar.NewInt("outbox.failed"),
+}
+
+// Relay polls the outbox and publishes pending messages. Messages of the same
+// aggregate are published strictly in order: while one is waiting for a retry,
+// the ones written after it are held back. A message that still fails after
+// the maximum number of attempts is marked as failed and left in the table for
+// inspection, releasing the messages behind it.
+//
+// Every replica may run a relay: a batch is only published by the relay
+// holding a MySQL named lock on the database, the others skip it.
+type Relay struct {
+	DB       *infras.MySQLConn
+	Producer producer.Producer
+	Config   *configs.Config
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// ProvideRelay is the provider for Relay.
+func ProvideRelay(db *infras.MySQLConn, producer producer.Producer, config *configs.Config) *Relay {
+	return &Relay{
+		DB:       db,
+		Producer: producer,
+		Config:   config,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the relay in the background on the configured interval.
+func (r *Relay) Start() {
+	if !r.Config.Event.Outbox.Enabled {
+		log.Info().Msg("Outbox relay is disabled.")
+		return
+	}
+
+	interval := r.interval()
+	log.Info().Dur("interval", interval).Int("batchSize", r.batchSize()).Msg("Outbox relay started.")
+
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := r.RunOnce(); err != nil {
+					logger.ErrorWithStack(err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the relay and waits for a batch in progress to finish.
+func (r *Relay) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+
+	if r.done != nil {
+		<-r.done
+		log.Info().Msg("Outbox relay stopped.")
+	}
+}
+
+// RunOnce publishes a single batch of pending messages and returns how many
+// were published. It publishes nothing while another relay holds the lock.
+func (r *Relay) RunOnce() (published int, err error) {
+	ctx := context.Background()
+
+	// a named lock belongs to the connection holding it, so keep one for the
+	// whole batch
+	conn, err := r.DB.Write.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var locked null.Int
+	err = conn.QueryRowContext(ctx, outboxQueries.acquireLock, r.lockName()).Scan(&locked)
+	if err != nil || locked.Int64 != 1 {
+		return
+	}
+	defer func() {
+		if _, releaseErr := conn.ExecContext(ctx, outboxQueries.releaseLock, r.lockName()); releaseErr != nil {
+			logger.ErrorWithStack(releaseErr)
+		}
+	}()
+
+	messages := make([]Message, 0)
+	now := time.Now()
+	// read from the primary so a replica lagging behind never hands out a
+	// message that was already sent
+	err = r.DB.Write.SelectContext(ctx, &messages, outboxQueries.selectPending, now, now, r.batchSize())
+	if err != nil {
+		return
+	}
+
+	// messages are published in rounds of at most one per aggregate, so the
+	// messages of an aggregate are never published out of order
+	keys := make([]string, 0)
+	queues := make(map[string][]Message)
+	for _, message := range messages {
+		key := message.orderingKey()
+		if _, ok := queues[key]; !ok {
+			keys = append(keys, key)
+		}
+		queues[key] = append(queues[key], message)
+	}
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		round := make([]Message, 0, len(keys))
+		for _, key := range keys {
+			if len(queues[key]) > 0 {
+				round = append(round, queues[key][0])
+				queues[key] = queues[key][1:]
+			}
+		}
+		if len(round) == 0 {
+			return
+		}
+
+		sent, markErr := r.relay(ctx, round)
+		if markErr != nil {
+			err = markErr
+		}
+
+		for i, message := range round {
+			if sent[i] {
+				published++
+			} else {
+				// hold back the messages written after it
+				queues[message.orderingKey()] = nil
+			}
+		}
+	}
+}
+
+// relay publishes messages in a single batch and records the outcome of each.
+func (r *Relay) relay(ctx context.Context, messages []Message) (sent []bool, err error) {
+	errs := make([]error, len(messages))
+	indexes := make([]int, 0, len(messages))
+	requests := make([]model.PublishRequest, 0, len(messages))
+	spans := make([]trace.Span, 0, len(messages))
+
+	for i, message := range messages {
+		request, requestErr := message.ToPublishRequest()
+		if requestErr != nil {
+			errs[i] = requestErr
+			continue
+		}
+
+		// the span continues the trace of the change that wrote the message
+		// and is passed on to consumers
+		spanCtx := tracing.Extract(ctx, request.Attributes)
+		spanCtx, span := tracing.Start(spanCtx, request.Topic+" send",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			tracing.MessagingAttributes("aws_sns", request.Topic))
+		request.Attributes = tracing.Inject(spanCtx, request.Attributes)
+
+		indexes = append(indexes, i)
+		requests = append(requests, request)
+		spans = append(spans, span)
+	}
+
+	if len(requests) > 0 {
+		results, _ := producer.PublishBatch(ctx, r.Producer, requests)
+		for j, result := range results {
+			errs[indexes[j]] = result.Err
+			tracing.End(spans[j], result.Err)
+		}
+	}
+
+	sent = make([]bool, len(messages))
+	for i, message := range messages {
+		var markErr error
+		sent[i], markErr = r.record(message, errs[i])
+		if markErr != nil {
+			logger.ErrorWithStack(markErr)
+			err = markErr
+		}
+	}
+
+	return
+}
+
+// record records the outcome of publishing a single message.
+func (r *Relay) record(message Message, publishErr error) (sent bool, err error) {
+	message.Attempts++
+	now := time.Now()
+
+	if publishErr == nil {
+		message.SentAt = null.TimeFrom(now)
+		metrics.published.Add(1)
+		// a message published but not marked sent is published again later,
+		// so delivery is at least once
+		_, err = r.DB.Write.NamedExec(outboxQueries.markSent, &message)
+		return true, err
+	}
+
+	message.LastError = null.StringFrom(publishErr.Error())
+
+	// a message rejected for its own content fails again on every retry
+	var entryErr *producer.BatchEntryError
+	senderFault := errors.As(publishErr, &entryErr) && entryErr.SenderFault
+
+	if senderFault || message.Attempts >= r.maxAttempts() {
+		message.FailedAt = null.TimeFrom(now)
+		metrics.failed.Add(1)
+		log.Error().
+			Str("id", message.ID.String()).
+			Str("eventType", message.EventType).
+			Int("attempts", message.Attempts).
+			Bool("senderFault", senderFault).
+			Msg("Outbox message failed permanently.")
+		_, err = r.DB.Write.NamedExec(outboxQueries.markFailed, &message)
+		return false, err
+	}
+
+	message.AvailableAt = now.Add(retryDelay(message.Attempts))
+	metrics.retried.Add(1)
+	log.Warn().
+		Str("id", message.ID.String()).
+		Str("eventType", message.EventType).
+		Int("attempts", message.Attempts).
+		Time("retryAt", message.AvailableAt).
+		Msg("Outbox message publish failed, will retry.")
+	_, err = r.DB.Write.NamedExec(outboxQueries.markRetry, &message)
+	return false, err
+}
+
+// retryDelay backs off exponentially from one second up to maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(attempts-1))) * time.Second
+	if delay <= 0 || delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
+
+// lockName is the name of the lock on the database, as named locks are shared
+// by every database of the server.
+func (r *Relay) lockName() string {
+	return "outbox_relay." + r.Config.DB.MySQL.Write.Name
+}
+
+func (r *Relay) batchSize() int {
+	if r.Config.Event.Outbox.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return r.Config.Event.Outbox.BatchSize
+}
+
+func (r *Relay) interval() time.Duration {
+	if r.Config.Event.Outbox.IntervalMilliseconds <= 0 {
+		return defaultIntervalMilliseconds * time.Millisecond
+	}
+	return time.Duration(r.Config.Event.Outbox.IntervalMilliseconds) * time.Millisecond
+}
+
+func (r *Relay) maxAttempts() int {
+	if r.Config.Event.Outbox.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return r.Config.Event.Outbox.MaxAttempts
+}
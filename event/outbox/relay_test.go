@@ -0,0 +1,234 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/outbox"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProducer struct {
+	failTopics map[string]bool
+	published  []model.PublishRequest
+}
+
+func (p *fakeProducer) Publish(request model.PublishRequest) error {
+	if p.failTopics[request.Topic] {
+		return errors.New("publish failed")
+	}
+	p.published = append(p.published, request)
+	return nil
+}
+
+type fakeBatchProducer struct {
+	fakeProducer
+	rejectTopics map[string]bool
+	batches      [][]string
+}
+
+func (p *fakeBatchProducer) PublishBatch(ctx context.Context, requests []model.PublishRequest) ([]producer.PublishResult, error) {
+	results := make([]producer.PublishResult, len(requests))
+	batch := make([]string, 0, len(requests))
+	for i, request := range requests {
+		if p.rejectTopics[request.Topic] {
+			results[i].Err = &producer.BatchEntryError{Code: "InvalidParameter", Message: "invalid attribute", SenderFault: true}
+		} else {
+			results[i].Err = p.Publish(request)
+		}
+		batch = append(batch, request.Event.Subject)
+	}
+	p.batches = append(p.batches, batch)
+	return results, nil
+}
+
+func pendingRows(messages ...outbox.Message) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{
+		"seq", "id", "aggregate_type", "aggregate_id", "topic", "event_type", "payload",
+		"message_group_id", "attempts", "last_error", "created_at", "available_at", "sent_at", "failed_at",
+	})
+	for i, m := range messages {
+		rows.AddRow(
+			i+1, m.ID.String(), m.AggregateType, m.AggregateID, m.Topic, m.EventType, m.Payload,
+			m.MessageGroupID, m.Attempts, m.LastError, m.CreatedAt, m.AvailableAt, m.SentAt, m.FailedAt)
+	}
+	return rows
+}
+
+func newMessage(t *testing.T, aggregateID string, topic string) outbox.Message {
+	message, err := outbox.NewMessage("foo", aggregateID, model.PublishRequest{
+		Event: model.NewEvent("foo.created", map[string]string{"id": aggregateID}).WithSubject(aggregateID),
+		Topic: topic,
+	})
+	assert.NoError(t, err)
+	message.AvailableAt = time.Now().Add(-time.Second)
+	return message
+}
+
+func expectLock(mock sqlmock.Sqlmock, locked int) {
+	mock.ExpectQuery("SELECT GET_LOCK\\(\\?, 0\\)").
+		WithArgs("outbox_relay.").
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(locked))
+}
+
+func expectRelease(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("DO RELEASE_LOCK\\(\\?\\)").
+		WithArgs("outbox_relay.").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestRelay(t *testing.T) {
+	t.Run("Skips the batch while another relay holds the lock", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock, 0)
+
+		producer := &fakeProducer{}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, &configs.Config{})
+		published, err := relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+		assert.Empty(t, producer.published)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Holds back messages of an aggregate after a failure", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		first := newMessage(t, "a", "failing")
+		second := newMessage(t, "a", "working")
+		other := newMessage(t, "b", "working")
+
+		expectLock(mock, 1)
+		mock.ExpectQuery("SELECT (.+) FROM outbox_messages").
+			WillReturnRows(pendingRows(first, second, other))
+		mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, last_error = \\?, available_at = \\?").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, sent_at = \\?").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectRelease(mock)
+
+		producer := &fakeProducer{failTopics: map[string]bool{"failing": true}}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, &configs.Config{})
+		published, err := relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, published)
+		assert.Len(t, producer.published, 1)
+		assert.Equal(t, "foo.created", producer.published[0].Event.EventType)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Marks a message failed after the last attempt", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		message := newMessage(t, "a", "failing")
+		message.Attempts = 2
+
+		expectLock(mock, 1)
+		mock.ExpectQuery("SELECT (.+) FROM outbox_messages").
+			WillReturnRows(pendingRows(message))
+		mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, last_error = \\?, failed_at = \\?").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectRelease(mock)
+
+		config := &configs.Config{}
+		config.Event.Outbox.MaxAttempts = 3
+
+		producer := &fakeProducer{failTopics: map[string]bool{"failing": true}}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, config)
+		published, err := relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Publishes one message per aggregate in each batch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock, 1)
+		mock.ExpectQuery("SELECT (.+) FROM outbox_messages").
+			WillReturnRows(pendingRows(newMessage(t, "a", "topic"), newMessage(t, "a", "topic"), newMessage(t, "b", "topic")))
+		for i := 0; i < 3; i++ {
+			mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, sent_at = \\?").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		expectRelease(mock)
+
+		producer := &fakeBatchProducer{}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, &configs.Config{})
+		published, err := relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, published)
+		assert.Equal(t, [][]string{{"a", "b"}, {"a"}}, producer.batches)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("Marks a message failed right away on a sender fault", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		expectLock(mock, 1)
+		mock.ExpectQuery("SELECT (.+) FROM outbox_messages").
+			WillReturnRows(pendingRows(newMessage(t, "a", "rejecting")))
+		mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, last_error = \\?, failed_at = \\?").
+			WithArgs(1, "InvalidParameter: invalid attribute", sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectRelease(mock)
+
+		producer := &fakeBatchProducer{rejectTopics: map[string]bool{"rejecting": true}}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, &configs.Config{})
+		published, err := relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deduplicates grouped messages by their ID", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		grouped := newMessage(t, "a", "topic.fifo")
+		grouped.MessageGroupID = null.StringFrom("a")
+		ungrouped := newMessage(t, "b", "topic")
+
+		expectLock(mock, 1)
+		mock.ExpectQuery("SELECT (.+) FROM outbox_messages").
+			WillReturnRows(pendingRows(grouped, ungrouped))
+		for i := 0; i < 2; i++ {
+			mock.ExpectExec("UPDATE outbox_messages SET attempts = \\?, sent_at = \\?").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		expectRelease(mock)
+
+		producer := &fakeBatchProducer{}
+		relay := outbox.ProvideRelay(infras.OpenMock(db), producer, &configs.Config{})
+		_, err = relay.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Len(t, producer.published, 2)
+		assert.Equal(t, grouped.ID.String(), *producer.published[0].DeduplicationID)
+		assert.Nil(t, producer.published[1].DeduplicationID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,55 @@
+package producer
+
+import (
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/rs/zerolog/log"
+)
+
+// publisher is a local broker that producers can publish to.
+type publisher interface {
+	Publish(name string, body []byte) error
+}
+
+// LocalProducer publishes to a local broker instead of SNS, wrapping every
+// CloudEvent in an SNS envelope.
+type LocalProducer struct {
+	broker publisher
+	source string
+}
+
+// NewMemoryProducer creates a producer publishing events from source to the
+// in-process broker.
+func NewMemoryProducer(source string) *LocalProducer {
+	log.Info().Msg("Memory Producer ready to publish messages.")
+	return &LocalProducer{broker: broker.DefaultMemory(), source: source}
+}
+
+// NewFileProducer creates a producer spooling events from source to a
+// directory.
+func NewFileProducer(directory string, source string) *LocalProducer {
+	log.Info().Str("directory", directory).Msg("File Producer ready to publish messages.")
+	return &LocalProducer{broker: &broker.File{Directory: directory}, source: source}
+}
+
+// Publish publishes a message to the local broker, using the topic as name.
+func (p *LocalProducer) Publish(request model.PublishRequest) error {
+	message, err := encode(request, p.source)
+	if err != nil {
+		return err
+	}
+
+	body, err := broker.Wrap(request.Topic, []byte(message), request.Attributes)
+	if err != nil {
+		return err
+	}
+
+	err = p.broker.Publish(request.Topic, body)
+	if err != nil {
+		log.Err(err).Str("topic", request.Topic).Msg("failed publishing message")
+		return err
+	}
+
+	log.Info().Str("topic", request.Topic).Msg("Published local message")
+	return nil
+}
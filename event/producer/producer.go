@@ -1,8 +1,100 @@
 package producer
 
-import "github.com/evermos/boilerplate-go/event/model"
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/broker"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+)
 
 // Producer represents an event producer interface.
 type Producer interface {
 	Publish(request model.PublishRequest) error
 }
+
+// BatchPublisher is a producer that can publish many messages with a single
+// call, e.g. SNSProducer.
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, requests []model.PublishRequest) ([]PublishResult, error)
+}
+
+// PublishBatch publishes requests with producer in a single call if it is a
+// BatchPublisher, or one by one otherwise. The results line up with the
+// requests, and the first error is returned.
+func PublishBatch(ctx context.Context, producer Producer, requests []model.PublishRequest) (results []PublishResult, err error) {
+	if batchPublisher, ok := producer.(BatchPublisher); ok {
+		return batchPublisher.PublishBatch(ctx, requests)
+	}
+
+	results = make([]PublishResult, len(requests))
+	for i, request := range requests {
+		results[i].Err = producer.Publish(request)
+		if results[i].Err != nil && err == nil {
+			err = results[i].Err
+		}
+	}
+	return
+}
+
+// ProvideProducer is the provider for the Producer selected by Event.Driver:
+// aws (the default), memory or file. Its publishes are counted in metrics.
+func ProvideProducer(config *configs.Config) Producer {
+	switch config.Event.Driver {
+	case broker.DriverMemory:
+		return Counted(NewMemoryProducer(config.App.Name))
+	case broker.DriverFile:
+		return Counted(NewFileProducer(config.Event.File.Directory, config.App.Name))
+	default:
+		return Counted(NewSNSProducer(config))
+	}
+}
+
+// Counted wraps a producer so the events it publishes are counted by type and
+// result.
+func Counted(producer Producer) Producer {
+	return countedProducer{producer}
+}
+
+type countedProducer struct {
+	Producer
+}
+
+func (p countedProducer) Publish(request model.PublishRequest) error {
+	err := p.Producer.Publish(request)
+	metrics.EventsPublished.WithLabelValues(request.Event.EventType, metrics.Result(err)).Inc()
+	return err
+}
+
+func (p countedProducer) PublishBatch(ctx context.Context, requests []model.PublishRequest) ([]PublishResult, error) {
+	results, err := PublishBatch(ctx, p.Producer, requests)
+	for i, result := range results {
+		metrics.EventsPublished.WithLabelValues(requests[i].Event.EventType, metrics.Result(result.Err)).Inc()
+	}
+	return results, err
+}
+
+// defaultSource is the source of events when App.Name is not set, as
+// CloudEvents require one.
+const defaultSource = "boilerplate-go"
+
+// encode encodes the event of a request as a CloudEvent from source, once its
+// data is validated against its schema.
+func encode(request model.PublishRequest, source string) (message string, err error) {
+	err = model.Schemas.ValidateEvent(request.Event)
+	if err != nil {
+		return
+	}
+
+	if source == "" {
+		source = defaultSource
+	}
+
+	body, err := json.Marshal(request.Event.ToCloudEvent(source))
+	if err != nil {
+		return
+	}
+	return string(body), nil
+}
@@ -1,96 +1,283 @@
 package producer
 
 import (
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/sns"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/rs/zerolog/log"
 )
 
-// SNSConfig is a wrapper for SNS configuration.
-type SNSConfig struct {
-	Config *configs.Config
-}
+const (
+	defaultTimeoutSeconds = 10
+	// maxBatchSize is the most entries SNS accepts in one batch publish.
+	maxBatchSize = 10
+)
 
-func createCredentials(config *configs.Config) credentials.Value {
-	return credentials.Value{
-		AccessKeyID:     config.Event.Producer.SNS.AccessKeyID,
-		SecretAccessKey: config.Event.Producer.SNS.SecretAccessKey,
-	}
+// PublishResult is the outcome of publishing a single message. SequenceNumber
+// is only set for FIFO topics. Err is set when the message was not published.
+type PublishResult struct {
+	MessageID      string
+	SequenceNumber string
+	Err            error
 }
 
-func createSNSConfig(config *configs.Config) (sessionSNS *session.Session, err error) {
-	sessionSNS, err = session.NewSession(&aws.Config{
-		Region:      aws.String(config.Event.Producer.SNS.Region),
-		Credentials: credentials.NewStaticCredentialsFromCreds(createCredentials(config)),
-	})
-
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed creating new SNS session")
-		return
-	}
+// BatchEntryError is why SNS rejected a single message of a batch. With
+// SenderFault set, the message itself is at fault and retrying it is useless.
+type BatchEntryError struct {
+	Code        string
+	Message     string
+	SenderFault bool
+}
 
-	return
+func (e *BatchEntryError) Error() string {
+	return e.Code + ": " + e.Message
 }
 
-// SNSProducer is an SNS producer.
+// SNSProducer is an SNS producer. Events are published in the CloudEvents
+// JSON format, with App.Name as their source. Every call to SNS times out
+// after Event.Producer.SNS.TimeoutSeconds.
 type SNSProducer struct {
 	config *configs.Config
-	sns    *sns.SNS
+	client *sns.Client
 }
 
 // NewSNSProducer creates a new object from Producer
 func NewSNSProducer(config *configs.Config) *SNSProducer {
-	sess, err := createSNSConfig(config)
+	snsConfig := config.Event.Producer.SNS
+	awsConfig, err := awsconfig.LoadDefaultConfig(
+		context.Background(),
+		awsconfig.WithRegion(snsConfig.Region),
+		awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(snsConfig.AccessKeyID, snsConfig.SecretAccessKey, "")),
+		awsconfig.WithRetryer(func() aws.Retryer {
+			return retry.AddWithMaxAttempts(retry.NewStandard(), snsConfig.MaxRetries+1)
+		}),
+	)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed creating SNS config")
 	}
-	log.Info().Str("region", *sess.Config.Region).Msg("SNS Producer ready to publish messages.")
-	return &SNSProducer{config: config, sns: sns.New(sess)}
+
+	log.Info().Str("region", snsConfig.Region).Msg("SNS Producer ready to publish messages.")
+	return &SNSProducer{config: config, client: sns.NewFromConfig(awsConfig, withEndpoint(config))}
+}
+
+// withEndpoint applies the configured endpoint, so the client can be pointed
+// at a local emulator instead of AWS. With path-style on, the SDK must not
+// rewrite the endpoint's host name.
+func withEndpoint(config *configs.Config) func(*sns.Options) {
+	snsConfig := config.Event.Producer.SNS
+
+	return func(options *sns.Options) {
+		options.EndpointOptions.DisableHTTPS = snsConfig.DisableSSL
+		if snsConfig.Endpoint == "" {
+			return
+		}
+
+		url := snsConfig.Endpoint
+		if !strings.Contains(url, "://") {
+			scheme := "https://"
+			if snsConfig.DisableSSL {
+				scheme = "http://"
+			}
+			url = scheme + url
+		}
+
+		options.EndpointResolver = sns.EndpointResolverFromURL(url, func(endpoint *aws.Endpoint) {
+			endpoint.HostnameImmutable = snsConfig.ForcePathStyle
+		})
+	}
 }
 
 // Publish publishes a message to SNS.
 func (p *SNSProducer) Publish(request model.PublishRequest) error {
-	err := p.sendMessage(&sns.PublishInput{
-		Message:        aws.String(string(request.Event.Data.Value)),
-		MessageGroupId: request.MessageGroupID,
-		TopicArn:       &request.Topic,
-	})
-
+	_, err := p.PublishWithContext(context.Background(), request)
 	return err
 }
 
-func (p *SNSProducer) sendMessage(msg *sns.PublishInput) error {
-	resp, err := p.sns.Publish(msg)
+// PublishWithContext publishes a message to SNS, giving up once ctx is done.
+// The trace context of ctx, if any, is sent along as message attributes.
+func (p *SNSProducer) PublishWithContext(ctx context.Context, request model.PublishRequest) (result PublishResult, err error) {
+	request.Attributes = tracing.Inject(ctx, request.Attributes)
+
+	message, err := encode(request, p.config.App.Name)
 	if err != nil {
-		log.Err(err).Interface("output", *msg).Msg("failed publishing message")
-		return err
+		return
 	}
 
-	logMsg := log.Info()
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
 
-	if msg.PhoneNumber != nil {
-		logMsg.Str("phoneNumber", *msg.PhoneNumber)
+	output, err := p.client.Publish(ctx, &sns.PublishInput{
+		Message:                aws.String(message),
+		MessageAttributes:      messageAttributes(request.Attributes),
+		MessageDeduplicationId: request.DeduplicationID,
+		MessageGroupId:         request.MessageGroupID,
+		TopicArn:               aws.String(request.Topic),
+	})
+	if err != nil {
+		log.Err(err).
+			Str("topicArn", request.Topic).
+			Str("eventType", request.Event.EventType).
+			Msg("failed publishing message")
+		return
 	}
 
-	if msg.TargetArn != nil {
-		logMsg.Str("targetArn", *msg.TargetArn)
+	result.MessageID = aws.ToString(output.MessageId)
+	result.SequenceNumber = aws.ToString(output.SequenceNumber)
+
+	log.Info().
+		Str("topicArn", request.Topic).
+		Str("eventType", request.Event.EventType).
+		Str("messageId", result.MessageID).
+		Msg("Published SNS message")
+
+	return
+}
+
+// PublishBatch publishes messages with as few calls as possible. Messages are
+// grouped by topic, keeping their order, and sent up to ten at a time. The
+// results line up with the requests; the result of every message that was
+// not published has its Err set, and the first of those errors is returned.
+// The trace context of ctx, if any, is sent along as message attributes.
+func (p *SNSProducer) PublishBatch(ctx context.Context, requests []model.PublishRequest) (results []PublishResult, err error) {
+	results = make([]PublishResult, len(requests))
+
+	traced := make([]model.PublishRequest, len(requests))
+	for i, request := range requests {
+		request.Attributes = tracing.Inject(ctx, request.Attributes)
+		traced[i] = request
 	}
+	requests = traced
 
-	if msg.TopicArn != nil {
-		logMsg.Str("topicArn", *msg.TopicArn)
+	topics := make([]string, 0)
+	indexesByTopic := make(map[string][]int)
+	for i, request := range requests {
+		if _, ok := indexesByTopic[request.Topic]; !ok {
+			topics = append(topics, request.Topic)
+		}
+		indexesByTopic[request.Topic] = append(indexesByTopic[request.Topic], i)
 	}
 
-	if resp != nil && resp.MessageId != nil {
-		logMsg.Str("messageId", *resp.MessageId)
+	for _, topic := range topics {
+		indexes := indexesByTopic[topic]
+		for start := 0; start < len(indexes); start += maxBatchSize {
+			end := start + maxBatchSize
+			if end > len(indexes) {
+				end = len(indexes)
+			}
+			p.publishBatch(ctx, topic, requests, indexes[start:end], results)
+		}
 	}
 
-	logMsg.
-		Interface("snsMessage", msg.Message).
-		Msg("Published SNS message")
+	for _, result := range results {
+		if result.Err != nil {
+			return results, result.Err
+		}
+	}
+
+	return
+}
+
+// publishBatch publishes the requests at indexes, which share a topic, in a
+// single call and records the outcomes in results.
+func (p *SNSProducer) publishBatch(ctx context.Context, topic string, requests []model.PublishRequest, indexes []int, results []PublishResult) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	entries := make([]types.PublishBatchRequestEntry, 0, len(indexes))
+	for _, index := range indexes {
+		request := requests[index]
+		message, err := encode(request, p.config.App.Name)
+		if err != nil {
+			results[index].Err = err
+			continue
+		}
+
+		entries = append(entries, types.PublishBatchRequestEntry{
+			Id:                     aws.String(strconv.Itoa(index)),
+			Message:                aws.String(message),
+			MessageAttributes:      messageAttributes(request.Attributes),
+			MessageDeduplicationId: request.DeduplicationID,
+			MessageGroupId:         request.MessageGroupID,
+		})
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	output, err := p.client.PublishBatch(ctx, &sns.PublishBatchInput{
+		PublishBatchRequestEntries: entries,
+		TopicArn:                   aws.String(topic),
+	})
+	if err != nil {
+		log.Err(err).Str("topicArn", topic).Int("count", len(entries)).Msg("failed publishing messages")
+		for _, index := range indexes {
+			if results[index].Err == nil {
+				results[index].Err = err
+			}
+		}
+		return
+	}
+
+	// entries are identified by their index in requests
+	for _, entry := range output.Successful {
+		index, _ := strconv.Atoi(aws.ToString(entry.Id))
+		results[index].MessageID = aws.ToString(entry.MessageId)
+		results[index].SequenceNumber = aws.ToString(entry.SequenceNumber)
+	}
+
+	for _, entry := range output.Failed {
+		index, _ := strconv.Atoi(aws.ToString(entry.Id))
+		results[index].Err = &BatchEntryError{
+			Code:        aws.ToString(entry.Code),
+			Message:     aws.ToString(entry.Message),
+			SenderFault: entry.SenderFault,
+		}
+		log.Error().
+			Err(results[index].Err).
+			Str("topicArn", topic).
+			Str("eventType", requests[index].Event.EventType).
+			Bool("senderFault", entry.SenderFault).
+			Msg("failed publishing message")
+	}
 
-	return nil
+	log.Info().
+		Str("topicArn", topic).
+		Int("published", len(output.Successful)).
+		Int("failed", len(output.Failed)).
+		Msg("Published SNS message batch")
+}
+
+func messageAttributes(attributes map[string]string) map[string]types.MessageAttributeValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	values := make(map[string]types.MessageAttributeValue, len(attributes))
+	for name, value := range attributes {
+		values[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+	return values
+}
+
+func (p *SNSProducer) timeout() time.Duration {
+	if p.config.Event.Producer.SNS.TimeoutSeconds <= 0 {
+		return defaultTimeoutSeconds * time.Second
+	}
+	return time.Duration(p.config.Event.Producer.SNS.TimeoutSeconds) * time.Second
 }
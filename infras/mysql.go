@@ -1,11 +1,14 @@
 package infras
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/url"
 
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 
 	"github.com/evermos/boilerplate-go/configs"
 	// use MySQL driver
@@ -28,12 +31,17 @@ type MySQLConn struct {
 	Write *sqlx.DB
 }
 
-// ProvideMySQLConn is the provider for MySQLConn.
+// ProvideMySQLConn is the provider for MySQLConn. The stats of both
+// connections are exposed as metrics.
 func ProvideMySQLConn(config *configs.Config) *MySQLConn {
-	return &MySQLConn{
+	conn := &MySQLConn{
 		Read:  CreateMySQLReadConn(*config),
 		Write: CreateMySQLWriteConn(*config),
 	}
+	metrics.RegisterDB("read", conn.Read.DB)
+	metrics.RegisterDB("write", conn.Write.DB)
+
+	return conn
 }
 
 // CreateMySQLWriteConn creates a database connection for write access.
@@ -108,8 +116,19 @@ func OpenMock(db *sql.DB) *MySQLConn {
 
 // WithTransaction performs queries with transaction
 func (m *MySQLConn) WithTransaction(block Block) (err error) {
+	return m.WithTransactionContext(context.Background(), block)
+}
+
+// WithTransactionContext performs queries with transaction, traced as a child
+// of the span in ctx.
+func (m *MySQLConn) WithTransactionContext(ctx context.Context, block Block) (err error) {
+	_, span := tracing.StartQuery(ctx, "MySQLConn.WithTransaction", "")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	e := make(chan error)
-	tx, err := m.Write.Beginx()
+	tx, err := m.Write.BeginTxx(ctx, nil)
 	if err != nil {
 		return
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/evermos/boilerplate-go/event/model"
 	"github.com/evermos/boilerplate-go/shared"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/nuuid"
@@ -34,7 +35,11 @@ const (
 )
 
 var (
-	FooBarBazEventType = "evm.boilerplate-go.foo-bar-baz.fifo"
+	// FooBarBazEventType is published when a Foo is created. Its schema is
+	// registered in model.Schemas.
+	FooBarBazEventType = model.FooBarBazEventType
+	// FooAggregateType identifies Foo in the outbox.
+	FooAggregateType = "foo"
 )
 
 //// Foo
@@ -3,13 +3,16 @@ package foobarbaz
 //go:generate go run github.com/golang/mock/mockgen -source foo_repository.go -destination mock/foo_repository_mock.go -package foobarbaz_mock
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
 
+	"github.com/evermos/boilerplate-go/event/outbox"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
 )
@@ -132,11 +135,11 @@ var (
 
 // FooRepository is the repository for Foo data.
 type FooRepository interface {
-	Create(foo Foo) (err error)
-	ExistsByID(id uuid.UUID) (exists bool, err error)
-	ResolveByID(id uuid.UUID) (foo Foo, err error)
-	ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []FooItem, err error)
-	Update(foo Foo) (err error)
+	Create(ctx context.Context, foo Foo, messages ...outbox.Message) (err error)
+	ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error)
+	ResolveByID(ctx context.Context, id uuid.UUID) (foo Foo, err error)
+	ResolveItemsByFooIDs(ctx context.Context, ids []uuid.UUID) (fooItems []FooItem, err error)
+	Update(ctx context.Context, foo Foo) (err error)
 }
 
 // FooRepositoryMySQL is the MySQL-backed implementation of FooRepository.
@@ -151,27 +154,38 @@ func ProvideFooRepositoryMySQL(db *infras.MySQLConn) *FooRepositoryMySQL {
 	return s
 }
 
-// Create creates a new Foo.
-func (r *FooRepositoryMySQL) Create(foo Foo) (err error) {
-	exists, err := r.ExistsByID(foo.ID)
+// Create creates a new Foo, writing any outbox messages about it in the same
+// transaction.
+func (r *FooRepositoryMySQL) Create(ctx context.Context, foo Foo, messages ...outbox.Message) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "FooRepository.Create", fooQueries.insertFoo)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	exists, err := r.ExistsByID(ctx, foo.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if exists {
 		err = failure.Conflict("create", "foo", "already exists")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := r.txCreate(tx, foo); err != nil {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txCreate(ctx, tx, foo); err != nil {
 			e <- err
 			return
 		}
 
-		if err := r.txCreateItems(tx, foo.Items); err != nil {
+		if err := r.txCreateItems(ctx, tx, foo.Items); err != nil {
+			e <- err
+			return
+		}
+
+		if err := outbox.Write(tx, messages...); err != nil {
 			e <- err
 			return
 		}
@@ -181,47 +195,66 @@ func (r *FooRepositoryMySQL) Create(foo Foo) (err error) {
 }
 
 // ExistsByID checks the existence of a Foo by its ID.
-func (r *FooRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = r.DB.Read.Get(
+func (r *FooRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	query := "SELECT COUNT(entity_id) FROM foo WHERE foo.entity_id = ?"
+	ctx, span := tracing.StartQuery(ctx, "FooRepository.ExistsByID", query)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	err = r.DB.Read.GetContext(
+		ctx,
 		&exists,
-		"SELECT COUNT(entity_id) FROM foo WHERE foo.entity_id = ?",
+		query,
 		id.String())
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
 // ResolveByID resolves a Foo by its ID
-func (r *FooRepositoryMySQL) ResolveByID(id uuid.UUID) (foo Foo, err error) {
-	err = r.DB.Read.Get(
+func (r *FooRepositoryMySQL) ResolveByID(ctx context.Context, id uuid.UUID) (foo Foo, err error) {
+	query := fooQueries.selectFoo + " WHERE foo.entity_id = ?"
+	ctx, span := tracing.StartQuery(ctx, "FooRepository.ResolveByID", query)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	err = r.DB.Read.GetContext(
+		ctx,
 		&foo,
-		fooQueries.selectFoo+" WHERE foo.entity_id = ?",
+		query,
 		id.String())
 	if err != nil && err == sql.ErrNoRows {
 		err = failure.NotFound("foo")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	return
 }
 
 // ResolveItemsByFooIDs resolves FooItems based on a set of FooIDs.
-func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []FooItem, err error) {
+func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ctx context.Context, ids []uuid.UUID) (fooItems []FooItem, err error) {
 	if len(ids) == 0 {
 		return
 	}
 
 	query, args, err := sqlx.In(fooQueries.selectFooItem+" WHERE foo_item.foo_id IN (?)", ids)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	err = r.DB.Read.Select(&fooItems, query, args...)
+	ctx, span := tracing.StartQuery(ctx, "FooRepository.ResolveItemsByFooIDs", query)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	err = r.DB.Read.SelectContext(ctx, &fooItems, query, args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
@@ -229,16 +262,21 @@ func (r *FooRepositoryMySQL) ResolveItemsByFooIDs(ids []uuid.UUID) (fooItems []F
 }
 
 // Update updates a Foo.
-func (r *FooRepositoryMySQL) Update(foo Foo) (err error) {
-	exists, err := r.ExistsByID(foo.ID)
+func (r *FooRepositoryMySQL) Update(ctx context.Context, foo Foo) (err error) {
+	ctx, span := tracing.StartQuery(ctx, "FooRepository.Update", fooQueries.updateFoo)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	exists, err := r.ExistsByID(ctx, foo.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if !exists {
 		err = failure.NotFound("foo")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
@@ -247,18 +285,18 @@ func (r *FooRepositoryMySQL) Update(foo Foo) (err error) {
 	// 1. delete all the Foo's items
 	// 2. create a new set of Foo's items
 	// 3. update the Foo
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
 		if err := r.txDeleteItems(tx, foo.ID); err != nil {
 			e <- err
 			return
 		}
 
-		if err := r.txCreateItems(tx, foo.Items); err != nil {
+		if err := r.txCreateItems(ctx, tx, foo.Items); err != nil {
 			e <- err
 			return
 		}
 
-		if err := r.txUpdate(tx, foo); err != nil {
+		if err := r.txUpdate(ctx, tx, foo); err != nil {
 			e <- err
 			return
 		}
@@ -296,24 +334,24 @@ func (r *FooRepositoryMySQL) composeBulkInsertItemQuery(fooItems []FooItem) (que
 }
 
 // txCreate creates a Foo transactionally given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txCreate(tx *sqlx.Tx, foo Foo) (err error) {
+func (r *FooRepositoryMySQL) txCreate(ctx context.Context, tx *sqlx.Tx, foo Foo) (err error) {
 	stmt, err := tx.PrepareNamed(fooQueries.insertFoo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
 	_, err = stmt.Exec(foo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
 // txCreateItems create FooItems transactionally given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txCreateItems(tx *sqlx.Tx, fooItems []FooItem) (err error) {
+func (r *FooRepositoryMySQL) txCreateItems(ctx context.Context, tx *sqlx.Tx, fooItems []FooItem) (err error) {
 	if len(fooItems) == 0 {
 		return
 	}
@@ -331,7 +369,7 @@ func (r *FooRepositoryMySQL) txCreateItems(tx *sqlx.Tx, fooItems []FooItem) (err
 
 	_, err = stmt.Stmt.Exec(args...)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
@@ -344,17 +382,17 @@ func (r *FooRepositoryMySQL) txDeleteItems(tx *sqlx.Tx, fooID uuid.UUID) (err er
 }
 
 // txUpdate updates a Foo transactionally, given the *sqlx.Tx param.
-func (r *FooRepositoryMySQL) txUpdate(tx *sqlx.Tx, foo Foo) (err error) {
+func (r *FooRepositoryMySQL) txUpdate(ctx context.Context, tx *sqlx.Tx, foo Foo) (err error) {
 	stmt, err := tx.PrepareNamed(fooQueries.updateFoo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
 	_, err = stmt.Exec(foo)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
@@ -3,40 +3,44 @@ package foobarbaz
 //go:generate go run github.com/golang/mock/mockgen -source foo_service.go -destination mock/foo_service_mock.go -package foobarbaz_mock
 
 import (
+	"context"
+
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/event/model"
-	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/event/outbox"
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/gofrs/uuid"
 )
 
-// FooService is the service interface for Foo entities.
+// FooService is the service interface for Foo entities. ctx carries the
+// trace of the request being served.
 type FooService interface {
-	Create(requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
-	ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err error)
-	SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, err error)
-	Update(id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
+	Create(ctx context.Context, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
+	ResolveByID(ctx context.Context, id uuid.UUID, withItems bool) (foo Foo, err error)
+	SoftDelete(ctx context.Context, id uuid.UUID, userID uuid.UUID) (foo Foo, err error)
+	Update(ctx context.Context, id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error)
 }
 
 // FooServiceImpl is the service implementation for Foo entities.
 type FooServiceImpl struct {
 	FooRepository FooRepository
-	Producer      producer.Producer
 	Config        *configs.Config
 }
 
 // ProvideFooServiceImpl is the provider for this service.
-func ProvideFooServiceImpl(fooRepository FooRepository, producer producer.Producer, config *configs.Config) *FooServiceImpl {
+func ProvideFooServiceImpl(fooRepository FooRepository, config *configs.Config) *FooServiceImpl {
 	s := new(FooServiceImpl)
 	s.FooRepository = fooRepository
 	s.Config = config
-	s.Producer = producer
 
 	return s
 }
 
-// Create creates a new Foo.
-func (s *FooServiceImpl) Create(requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
+// Create creates a new Foo. Its event carries the trace context of ctx, so
+// publishing it joins the same trace.
+func (s *FooServiceImpl) Create(ctx context.Context, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
 	foo, err = foo.NewFromRequestFormat(requestFormat, userID)
 	if err != nil {
 		return
@@ -46,33 +50,42 @@ func (s *FooServiceImpl) Create(requestFormat FooRequestFormat, userID uuid.UUID
 		return foo, failure.BadRequest(err)
 	}
 
-	err = s.FooRepository.Create(foo)
+	messages := make([]outbox.Message, 0)
+	if s.Config.Event.Producer.SNS.Topics.FooCreated.Enabled {
+		// the event is written to the outbox and published by the relay once
+		// the Foo is committed
+		groupID := foo.ID.String()
+		message, err := outbox.NewMessage(FooAggregateType, foo.ID.String(), model.PublishRequest{
+			Attributes:     tracing.Inject(ctx, nil),
+			Event:          model.NewEvent(FooBarBazEventType, foo).WithSubject(foo.ID.String()),
+			MessageGroupID: &groupID,
+			Topic:          s.Config.Event.Producer.SNS.Topics.FooCreated.ARN,
+		})
+		if err != nil {
+			return foo, err
+		}
+		messages = append(messages, message)
+	}
 
+	err = s.FooRepository.Create(ctx, foo, messages...)
 	if err != nil {
 		return
 	}
 
-	if s.Config.Event.Producer.SNS.Topics.FooCreated.Enabled {
-		e := model.NewEvent(FooBarBazEventType, requestFormat)
-		s.Producer.Publish(model.PublishRequest{
-			Event: e,
-			Topic: s.Config.Event.Producer.SNS.Topics.FooCreated.ARN,
-		})
-	}
-
+	logger.FromContext(ctx).Info().Str("fooId", foo.ID.String()).Msg("Created foo.")
 	return
 }
 
 // ResolveByID resolves a Foo by its ID.
-func (s *FooServiceImpl) ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) ResolveByID(ctx context.Context, id uuid.UUID, withItems bool) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 
 	if foo.IsDeleted() {
 		return foo, failure.NotFound("foo")
 	}
 
 	if withItems {
-		items, err := s.FooRepository.ResolveItemsByFooIDs([]uuid.UUID{foo.ID})
+		items, err := s.FooRepository.ResolveItemsByFooIDs(ctx, []uuid.UUID{foo.ID})
 		if err != nil {
 			return foo, err
 		}
@@ -84,14 +97,14 @@ func (s *FooServiceImpl) ResolveByID(id uuid.UUID, withItems bool) (foo Foo, err
 }
 
 // SoftDelete marks a Foo as deleted by setting its `deleted` and `deletedBy` properties.
-func (s *FooServiceImpl) SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) SoftDelete(ctx context.Context, id uuid.UUID, userID uuid.UUID) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 	if err != nil {
 		return
 	}
 
 	// need to get the items so they don't get deleted
-	items, err := s.FooRepository.ResolveItemsByFooIDs([]uuid.UUID{foo.ID})
+	items, err := s.FooRepository.ResolveItemsByFooIDs(ctx, []uuid.UUID{foo.ID})
 	if err != nil {
 		return foo, err
 	}
@@ -103,13 +116,13 @@ func (s *FooServiceImpl) SoftDelete(id uuid.UUID, userID uuid.UUID) (foo Foo, er
 		return
 	}
 
-	err = s.FooRepository.Update(foo)
+	err = s.FooRepository.Update(ctx, foo)
 	return
 }
 
 // Update updates a Foo.
-func (s *FooServiceImpl) Update(id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
-	foo, err = s.FooRepository.ResolveByID(id)
+func (s *FooServiceImpl) Update(ctx context.Context, id uuid.UUID, requestFormat FooRequestFormat, userID uuid.UUID) (foo Foo, err error) {
+	foo, err = s.FooRepository.ResolveByID(ctx, id)
 	if err != nil {
 		return
 	}
@@ -119,6 +132,6 @@ func (s *FooServiceImpl) Update(id uuid.UUID, requestFormat FooRequestFormat, us
 		return
 	}
 
-	err = s.FooRepository.Update(foo)
+	err = s.FooRepository.Update(ctx, foo)
 	return
 }
@@ -1,6 +1,7 @@
 package foobarbaz_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -38,8 +39,8 @@ func TestFooService(t *testing.T) {
 				name:     "default",
 				entityID: uuidFromString("4e80c5bf-b79b-4c90-8f91-82647f439e55"),
 				setupMock: func(mockRepo *foobarbaz_mock.MockFooRepository, id uuid.UUID, ent foobarbaz.Foo, entItems []foobarbaz.FooItem, err error) {
-					mockRepo.EXPECT().ResolveByID(id).Return(ent, err)
-					mockRepo.EXPECT().ResolveItemsByFooIDs([]uuid.UUID{id}).Return(entItems, err)
+					mockRepo.EXPECT().ResolveByID(gomock.Any(), id).Return(ent, err)
+					mockRepo.EXPECT().ResolveItemsByFooIDs(gomock.Any(), []uuid.UUID{id}).Return(entItems, err)
 				},
 				returns: &foobarbaz.Foo{
 					ID:            uuidFromString("4e80c5bf-b79b-4c90-8f91-82647f439e55"),
@@ -93,7 +94,7 @@ func TestFooService(t *testing.T) {
 					FooRepository: mockRepo,
 				}
 				test.setupMock(mockRepo, test.entityID, *test.returns, *test.returnItems, test.err)
-				got, err := s.ResolveByID(test.entityID, true)
+				got, err := s.ResolveByID(context.Background(), test.entityID, true)
 
 				assert.Equal(t, test.err, err)
 				assert.Equal(t, test.returns.Name, got.Name)
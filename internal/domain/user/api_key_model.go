@@ -0,0 +1,217 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// APIKeyScopeProfileRead allows reading the owner's profile.
+	APIKeyScopeProfileRead = "profile:read"
+	// APIKeyScopeProfileWrite allows updating the owner's profile.
+	APIKeyScopeProfileWrite = "profile:write"
+
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 32
+)
+
+// APIKeyScopes is the list of scopes granted to an API key, stored as a
+// comma-separated string.
+type APIKeyScopes []string
+
+// Scan implements the Scanner interface.
+func (s *APIKeyScopes) Scan(value interface{}) error {
+	switch x := value.(type) {
+	case []byte:
+		*s = splitScopes(string(x))
+	case string:
+		*s = splitScopes(x)
+	case nil:
+		*s = APIKeyScopes{}
+	default:
+		return fmt.Errorf("cannot scan type %T into user.APIKeyScopes: %v", value, value)
+	}
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (s APIKeyScopes) Value() (driver.Value, error) {
+	return strings.Join(s, ","), nil
+}
+
+func splitScopes(str string) APIKeyScopes {
+	scopes := APIKeyScopes{}
+	for _, scope := range strings.Split(str, ",") {
+		if scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// APIKey is a user-owned key used for machine-to-machine access. Only the
+// hash of the key is stored; the prefix is kept in plain text for lookup.
+type APIKey struct {
+	ID        uuid.UUID    `db:"id" validate:"required"`
+	UserID    uuid.UUID    `db:"user_id" validate:"required"`
+	Name      string       `db:"name" validate:"required"`
+	Prefix    string       `db:"prefix" validate:"required"`
+	KeyHash   string       `db:"key_hash" validate:"required"`
+	Scopes    APIKeyScopes `db:"scopes" validate:"required,min=1,dive,oneof=profile:read profile:write"`
+	ExpiresAt time.Time    `db:"expires_at" validate:"required"`
+	CreatedAt time.Time    `db:"created_at" validate:"required"`
+	RevokedAt null.Time    `db:"revoked_at"`
+}
+
+// NewAPIKeyFromRequestFormat creates a new APIKey for a user. The plain key is
+// returned separately since it is never stored.
+func (k APIKey) NewAPIKeyFromRequestFormat(req APIKeyRequestFormat, userID uuid.UUID) (newAPIKey APIKey, key string, err error) {
+	if !req.ExpiresAt.After(time.Now()) {
+		err = failure.BadRequestFromString("expiresAt must be in the future")
+		return
+	}
+
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		err = failure.InternalError(err)
+		return
+	}
+
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		err = failure.InternalError(err)
+		return
+	}
+
+	key = prefix + "." + secret
+	apiKeyID, _ := uuid.NewV4()
+
+	newAPIKey = APIKey{
+		ID:        apiKeyID,
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(key),
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	err = newAPIKey.Validate()
+
+	return
+}
+
+// IsActive checks whether the APIKey is neither revoked nor expired.
+func (k *APIKey) IsActive() bool {
+	return !k.RevokedAt.Valid && time.Now().Before(k.ExpiresAt)
+}
+
+// HasScope checks whether the APIKey was granted a scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches checks a plain key against the stored hash in constant time.
+func (k *APIKey) Matches(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(hashAPIKey(key))) == 1
+}
+
+// Revoke marks the APIKey as revoked.
+func (k *APIKey) Revoke() (err error) {
+	if k.RevokedAt.Valid {
+		return failure.Conflict("revoke", "apiKey", "already revoked")
+	}
+
+	k.RevokedAt = null.TimeFrom(time.Now())
+
+	return
+}
+
+// Validate validates the entity.
+func (k *APIKey) Validate() (err error) {
+	validator := shared.GetValidator()
+	return validator.Struct(k)
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (k APIKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.ToResponseFormat())
+}
+
+// ToResponseFormat converts this APIKey to its response format.
+func (k APIKey) ToResponseFormat() APIKeyResponseFormat {
+	return APIKeyResponseFormat{
+		ID:        k.ID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    k.Scopes,
+		ExpiresAt: k.ExpiresAt,
+		CreatedAt: k.CreatedAt,
+		RevokedAt: k.RevokedAt,
+	}
+}
+
+// ParseAPIKeyPrefix extracts the lookup prefix from a plain key.
+func ParseAPIKeyPrefix(key string) (prefix string, ok bool) {
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) != 2 || len(parts[0]) != apiKeyPrefixBytes*2 || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// APIKeyRequestFormat represents an APIKey's standard formatting for JSON deserializing.
+type APIKeyRequestFormat struct {
+	Name      string    `json:"name" validate:"required"`
+	ExpiresAt time.Time `json:"expiresAt" validate:"required"`
+	Scopes    []string  `json:"scopes" validate:"required,min=1,dive,oneof=profile:read profile:write"`
+}
+
+// APIKeyResponseFormat represents an APIKey's standard formatting for JSON serializing.
+type APIKeyResponseFormat struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Prefix    string    `json:"prefix"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	RevokedAt null.Time `json:"revokedAt"`
+}
+
+// APIKeyCreatedResponseFormat is returned once on creation and is the only
+// time the plain key is ever shown.
+type APIKeyCreatedResponseFormat struct {
+	APIKeyResponseFormat
+	Key string `json:"key"`
+}
@@ -0,0 +1,209 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	apiKeyQueries = struct {
+		selectAPIKey     string
+		insertAPIKey     string
+		revokeAPIKey     string
+		revokeAllAPIKeys string
+	}{
+		selectAPIKey: `
+			SELECT
+				id,
+				user_id,
+				name,
+				prefix,
+				key_hash,
+				scopes,
+				expires_at,
+				created_at,
+				revoked_at
+			FROM api_keys
+		`,
+		insertAPIKey: `
+			INSERT INTO api_keys (
+				id,
+				user_id,
+				name,
+				prefix,
+				key_hash,
+				scopes,
+				expires_at,
+				created_at,
+				revoked_at
+			) VALUES (
+				:id,
+				:user_id,
+				:name,
+				:prefix,
+				:key_hash,
+				:scopes,
+				:expires_at,
+				:created_at,
+				:revoked_at
+			)
+		`,
+		revokeAPIKey: `
+			UPDATE api_keys
+			SET
+				revoked_at = :revoked_at
+			WHERE
+				id = :id
+		`,
+		revokeAllAPIKeys: `
+			UPDATE api_keys
+			SET
+				revoked_at = ?
+			WHERE
+				user_id = ? AND revoked_at IS NULL
+		`,
+	}
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, apiKey APIKey) (err error)
+	ResolveByID(ctx context.Context, id uuid.UUID) (apiKey APIKey, err error)
+	ResolveByPrefix(ctx context.Context, prefix string) (apiKey APIKey, err error)
+	ResolveByUserID(ctx context.Context, userID uuid.UUID) (apiKeys []APIKey, err error)
+	Revoke(ctx context.Context, apiKey APIKey) (err error)
+	RevokeAllByUserID(ctx context.Context, userID uuid.UUID) (err error)
+}
+
+type APIKeyRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvideAPIKeyRepositoryMySQL(db *infras.MySQLConn) *APIKeyRepositoryMySQL {
+	s := new(APIKeyRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *APIKeyRepositoryMySQL) Create(ctx context.Context, apiKey APIKey) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txCreate(ctx, tx, apiKey); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+func (r *APIKeyRepositoryMySQL) ResolveByID(ctx context.Context, id uuid.UUID) (apiKey APIKey, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
+		&apiKey,
+		apiKeyQueries.selectAPIKey+" WHERE id = ?",
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("apiKey")
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+
+	return
+}
+
+func (r *APIKeyRepositoryMySQL) ResolveByPrefix(ctx context.Context, prefix string) (apiKey APIKey, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
+		&apiKey,
+		apiKeyQueries.selectAPIKey+" WHERE prefix = ?",
+		prefix)
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("apiKey")
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+
+	return
+}
+
+func (r *APIKeyRepositoryMySQL) ResolveByUserID(ctx context.Context, userID uuid.UUID) (apiKeys []APIKey, err error) {
+	apiKeys = make([]APIKey, 0)
+	err = r.DB.Read.SelectContext(
+		ctx,
+		&apiKeys,
+		apiKeyQueries.selectAPIKey+" WHERE user_id = ? ORDER BY created_at DESC",
+		userID.String())
+
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+func (r *APIKeyRepositoryMySQL) Revoke(ctx context.Context, apiKey APIKey) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txRevoke(ctx, tx, apiKey); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// RevokeAllByUserID revokes every active API key of a user.
+func (r *APIKeyRepositoryMySQL) RevokeAllByUserID(ctx context.Context, userID uuid.UUID) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		_, err := tx.ExecContext(ctx, apiKeyQueries.revokeAllAPIKeys, time.Now(), userID.String())
+		if err != nil {
+			logger.ErrorWithStackContext(ctx, err)
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// Internal Functions
+func (r *APIKeyRepositoryMySQL) txCreate(ctx context.Context, tx *sqlx.Tx, apiKey APIKey) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, apiKeyQueries.insertAPIKey)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, apiKey)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+func (r *APIKeyRepositoryMySQL) txRevoke(ctx context.Context, tx *sqlx.Tx, apiKey APIKey) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, apiKeyQueries.revokeAPIKey)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, apiKey)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
@@ -0,0 +1,109 @@
+package user
+
+import (
+	"context"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt"
+)
+
+type APIKeyService interface {
+	Create(ctx context.Context, requestFormat APIKeyRequestFormat, userID uuid.UUID) (apiKey APIKey, key string, err error)
+	ResolveByUserID(ctx context.Context, userID uuid.UUID) (apiKeys []APIKey, err error)
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) (apiKey APIKey, err error)
+	Authenticate(ctx context.Context, key string) (claims *shared.Claims, err error)
+	ParsePrefix(key string) (prefix string, ok bool)
+}
+
+type APIKeyServiceImpl struct {
+	APIKeyRepository APIKeyRepository
+	UserRepository   UserRepository
+}
+
+func ProvideAPIKeyServiceImpl(apiKeyRepository APIKeyRepository, userRepository UserRepository) *APIKeyServiceImpl {
+	s := new(APIKeyServiceImpl)
+	s.APIKeyRepository = apiKeyRepository
+	s.UserRepository = userRepository
+
+	return s
+}
+
+func (s *APIKeyServiceImpl) Create(ctx context.Context, requestFormat APIKeyRequestFormat, userID uuid.UUID) (apiKey APIKey, key string, err error) {
+	apiKey, key, err = apiKey.NewAPIKeyFromRequestFormat(requestFormat, userID)
+	if err != nil {
+		return
+	}
+
+	err = s.APIKeyRepository.Create(ctx, apiKey)
+	return
+}
+
+func (s *APIKeyServiceImpl) ResolveByUserID(ctx context.Context, userID uuid.UUID) (apiKeys []APIKey, err error) {
+	return s.APIKeyRepository.ResolveByUserID(ctx, userID)
+}
+
+func (s *APIKeyServiceImpl) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) (apiKey APIKey, err error) {
+	apiKey, err = s.APIKeyRepository.ResolveByID(ctx, id)
+	if err != nil {
+		return
+	}
+
+	// do not disclose keys owned by someone else
+	if apiKey.UserID != userID {
+		return APIKey{}, failure.NotFound("apiKey")
+	}
+
+	err = apiKey.Revoke()
+	if err != nil {
+		return
+	}
+
+	err = s.APIKeyRepository.Revoke(ctx, apiKey)
+	return
+}
+
+// ParsePrefix extracts the lookup prefix from a plain key, which is safe to
+// log or audit unlike the key itself.
+func (s *APIKeyServiceImpl) ParsePrefix(key string) (prefix string, ok bool) {
+	return ParseAPIKeyPrefix(key)
+}
+
+// Authenticate resolves the owner of a plain key and returns the same claims
+// a JWT for that user would carry, limited to the key's scopes.
+func (s *APIKeyServiceImpl) Authenticate(ctx context.Context, key string) (claims *shared.Claims, err error) {
+	invalidKeyError := failure.Unauthorized("Invalid API key")
+
+	prefix, ok := ParseAPIKeyPrefix(key)
+	if !ok {
+		return nil, invalidKeyError
+	}
+
+	apiKey, err := s.APIKeyRepository.ResolveByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, invalidKeyError
+	}
+
+	if !apiKey.Matches(key) || !apiKey.IsActive() {
+		return nil, invalidKeyError
+	}
+
+	user, err := s.UserRepository.ResolveByID(ctx, apiKey.UserID)
+	if err != nil || user.IsDeleted() {
+		return nil, invalidKeyError
+	}
+
+	claims = &shared.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+		Scopes:   apiKey.Scopes,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: apiKey.ExpiresAt.Unix(),
+			Id:        apiKey.ID.String(),
+		},
+	}
+
+	return claims, nil
+}
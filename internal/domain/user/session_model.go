@@ -0,0 +1,108 @@
+package user
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+	"github.com/guregu/null"
+)
+
+const maxUserAgentLength = 512
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is a single login of a user on a device.
+type Session struct {
+	ID         uuid.UUID `db:"id" validate:"required"`
+	UserID     uuid.UUID `db:"user_id" validate:"required"`
+	UserAgent  string    `db:"user_agent"`
+	IPAddress  string    `db:"ip_address"`
+	CreatedAt  time.Time `db:"created_at" validate:"required"`
+	LastSeenAt time.Time `db:"last_seen_at" validate:"required"`
+	ExpiresAt  time.Time `db:"expires_at" validate:"required"`
+	RevokedAt  null.Time `db:"revoked_at"`
+}
+
+// NewSession creates a new Session for a user logging in from a device. The
+// session expires together with the token issued for it.
+func (s Session) NewSession(userID uuid.UUID, client ClientInfo) (newSession Session, err error) {
+	sessionID, _ := uuid.NewV4()
+	now := time.Now()
+
+	// the column holds characters, so never cut a multi-byte one in half
+	userAgent := client.UserAgent
+	if runes := []rune(userAgent); len(runes) > maxUserAgentLength {
+		userAgent = string(runes[:maxUserAgentLength])
+	}
+
+	newSession = Session{
+		ID:         sessionID,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(shared.TokenLifetime),
+	}
+
+	err = newSession.Validate()
+
+	return
+}
+
+// IsActive checks whether the Session is neither revoked nor expired.
+func (s *Session) IsActive() bool {
+	return !s.RevokedAt.Valid && time.Now().Before(s.ExpiresAt)
+}
+
+// Revoke marks the Session as revoked.
+func (s *Session) Revoke() (err error) {
+	if s.RevokedAt.Valid {
+		return failure.Conflict("revoke", "session", "already revoked")
+	}
+
+	s.RevokedAt = null.TimeFrom(time.Now())
+
+	return
+}
+
+// Validate validates the entity.
+func (s *Session) Validate() (err error) {
+	validator := shared.GetValidator()
+	return validator.Struct(s)
+}
+
+// MarshalJSON overrides the standard JSON formatting.
+func (s Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToResponseFormat())
+}
+
+// ToResponseFormat converts this Session to its response format.
+func (s Session) ToResponseFormat() SessionResponseFormat {
+	return SessionResponseFormat{
+		ID:         s.ID,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
+
+// SessionResponseFormat represents a Session's standard formatting for JSON serializing.
+type SessionResponseFormat struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,42 @@
+package user_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionNewSession(t *testing.T) {
+	userID, _ := uuid.NewV4()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  string
+	}{
+		{
+			name:      "Keeps a multi-byte user agent at the limit",
+			userAgent: strings.Repeat("a", 511) + "é",
+			expected:  strings.Repeat("a", 511) + "é",
+		},
+		{
+			name:      "Truncates a multi-byte user agent by characters",
+			userAgent: strings.Repeat("é", 513),
+			expected:  strings.Repeat("é", 512),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := user.Session{}.NewSession(userID, user.ClientInfo{UserAgent: test.userAgent})
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, session.UserAgent)
+			assert.True(t, utf8.ValidString(session.UserAgent))
+		})
+	}
+}
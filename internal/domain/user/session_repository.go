@@ -0,0 +1,213 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	sessionQueries = struct {
+		selectSession    string
+		insertSession    string
+		revokeSession    string
+		revokeAllSession string
+		touchSession     string
+	}{
+		selectSession: `
+			SELECT
+				id,
+				user_id,
+				user_agent,
+				ip_address,
+				created_at,
+				last_seen_at,
+				expires_at,
+				revoked_at
+			FROM sessions
+		`,
+		insertSession: `
+			INSERT INTO sessions (
+				id,
+				user_id,
+				user_agent,
+				ip_address,
+				created_at,
+				last_seen_at,
+				expires_at,
+				revoked_at
+			) VALUES (
+				:id,
+				:user_id,
+				:user_agent,
+				:ip_address,
+				:created_at,
+				:last_seen_at,
+				:expires_at,
+				:revoked_at
+			)
+		`,
+		revokeSession: `
+			UPDATE sessions
+			SET
+				revoked_at = :revoked_at
+			WHERE
+				id = :id
+		`,
+		revokeAllSession: `
+			UPDATE sessions
+			SET
+				revoked_at = ?
+			WHERE
+				user_id = ? AND id <> ? AND revoked_at IS NULL
+		`,
+		touchSession: `
+			UPDATE sessions
+			SET
+				last_seen_at = ?
+			WHERE
+				id = ? AND last_seen_at < ?
+		`,
+	}
+)
+
+type SessionRepository interface {
+	Create(ctx context.Context, session Session) (err error)
+	ResolveByID(ctx context.Context, id uuid.UUID) (session Session, err error)
+	ResolveActiveByUserID(ctx context.Context, userID uuid.UUID) (sessions []Session, err error)
+	Revoke(ctx context.Context, session Session) (err error)
+	RevokeAllByUserID(ctx context.Context, userID uuid.UUID, exceptID uuid.UUID) (err error)
+	Touch(ctx context.Context, id uuid.UUID, seenAt time.Time, staleBefore time.Time) (err error)
+}
+
+type SessionRepositoryMySQL struct {
+	DB *infras.MySQLConn
+}
+
+func ProvideSessionRepositoryMySQL(db *infras.MySQLConn) *SessionRepositoryMySQL {
+	s := new(SessionRepositoryMySQL)
+	s.DB = db
+
+	return s
+}
+
+func (r *SessionRepositoryMySQL) Create(ctx context.Context, session Session) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txCreate(ctx, tx, session); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// ResolveByID resolves a Session by its ID. It reads from the primary, so a
+// session revoked a moment ago is never seen as active on a lagging replica.
+func (r *SessionRepositoryMySQL) ResolveByID(ctx context.Context, id uuid.UUID) (session Session, err error) {
+	err = r.DB.Write.GetContext(
+		ctx,
+		&session,
+		sessionQueries.selectSession+" WHERE id = ?",
+		id.String())
+
+	if err != nil && err == sql.ErrNoRows {
+		err = failure.NotFound("session")
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+
+	return
+}
+
+func (r *SessionRepositoryMySQL) ResolveActiveByUserID(ctx context.Context, userID uuid.UUID) (sessions []Session, err error) {
+	sessions = make([]Session, 0)
+	err = r.DB.Read.SelectContext(
+		ctx,
+		&sessions,
+		sessionQueries.selectSession+" WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC",
+		userID.String(),
+		time.Now())
+
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+func (r *SessionRepositoryMySQL) Revoke(ctx context.Context, session Session) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txRevoke(ctx, tx, session); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// RevokeAllByUserID revokes every active session of a user except exceptID.
+// Pass uuid.Nil to revoke all of them.
+func (r *SessionRepositoryMySQL) RevokeAllByUserID(ctx context.Context, userID uuid.UUID, exceptID uuid.UUID) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		_, err := tx.ExecContext(ctx, sessionQueries.revokeAllSession, time.Now(), userID.String(), exceptID.String())
+		if err != nil {
+			logger.ErrorWithStackContext(ctx, err)
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// Touch records activity on a session, skipping the write when the session
+// was already seen after staleBefore.
+func (r *SessionRepositoryMySQL) Touch(ctx context.Context, id uuid.UUID, seenAt time.Time, staleBefore time.Time) (err error) {
+	_, err = r.DB.Write.ExecContext(ctx, sessionQueries.touchSession, seenAt, id.String(), staleBefore)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+// Internal Functions
+func (r *SessionRepositoryMySQL) txCreate(ctx context.Context, tx *sqlx.Tx, session Session) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, sessionQueries.insertSession)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, session)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+func (r *SessionRepositoryMySQL) txRevoke(ctx context.Context, tx *sqlx.Tx, session Session) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, sessionQueries.revokeSession)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, session)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
@@ -0,0 +1,72 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/gofrs/uuid"
+)
+
+// sessionTouchInterval limits how often a session's last-seen time is written.
+const sessionTouchInterval = time.Minute
+
+type SessionService interface {
+	ResolveActiveByUserID(ctx context.Context, userID uuid.UUID) (sessions []Session, err error)
+	Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) (session Session, err error)
+	Verify(ctx context.Context, id uuid.UUID, userID uuid.UUID) (err error)
+}
+
+type SessionServiceImpl struct {
+	SessionRepository SessionRepository
+}
+
+func ProvideSessionServiceImpl(sessionRepository SessionRepository) *SessionServiceImpl {
+	s := new(SessionServiceImpl)
+	s.SessionRepository = sessionRepository
+
+	return s
+}
+
+func (s *SessionServiceImpl) ResolveActiveByUserID(ctx context.Context, userID uuid.UUID) (sessions []Session, err error) {
+	return s.SessionRepository.ResolveActiveByUserID(ctx, userID)
+}
+
+func (s *SessionServiceImpl) Revoke(ctx context.Context, id uuid.UUID, userID uuid.UUID) (session Session, err error) {
+	session, err = s.SessionRepository.ResolveByID(ctx, id)
+	if err != nil {
+		return
+	}
+
+	// do not disclose sessions owned by someone else
+	if session.UserID != userID {
+		return Session{}, failure.NotFound("session")
+	}
+
+	err = session.Revoke()
+	if err != nil {
+		return
+	}
+
+	err = s.SessionRepository.Revoke(ctx, session)
+	return
+}
+
+// Verify checks that a token's session is still active and records the
+// activity as the session's last-seen time.
+func (s *SessionServiceImpl) Verify(ctx context.Context, id uuid.UUID, userID uuid.UUID) (err error) {
+	session, err := s.SessionRepository.ResolveByID(ctx, id)
+	if err != nil {
+		return failure.Unauthorized("Session not found")
+	}
+
+	if session.UserID != userID || !session.IsActive() {
+		return failure.Unauthorized("Session revoked")
+	}
+
+	// failing to record activity should not lock the user out
+	now := time.Now()
+	_ = s.SessionRepository.Touch(ctx, session.ID, now, now.Add(-sessionTouchInterval))
+
+	return nil
+}
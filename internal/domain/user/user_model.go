@@ -12,6 +12,22 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// RoleAdmin is granted to administrators. It cannot be self-registered.
+	RoleAdmin = "admin"
+)
+
+// UserAggregateType identifies User in the outbox.
+const UserAggregateType = "user"
+
+// User lifecycle event types. Every event carries a UserEventPayload.
+var (
+	UserRegisteredEventType      = "user.registered"
+	UserUpdatedEventType         = "user.updated"
+	UserDeletedEventType         = "user.deleted"
+	UserPasswordChangedEventType = "user.password_changed"
+)
+
 type User struct {
 	ID        uuid.UUID   `db:"id" validate:"required"`
 	Username  string      `db:"username" validate:"required"`
@@ -81,6 +97,54 @@ func (u User) ToResponseFormat() UserResponseFormat {
 	return resp
 }
 
+// ToEventPayload converts this User to the payload of its lifecycle events.
+func (u User) ToEventPayload() UserEventPayload {
+	return UserEventPayload{
+		ID:        u.ID,
+		Username:  u.Username,
+		Name:      u.Name,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		UpdatedBy: u.UpdatedBy.Ptr(),
+		DeletedAt: u.DeletedAt,
+		DeletedBy: u.DeletedBy.Ptr(),
+	}
+}
+
+// ChangePassword replaces the password after checking the current one.
+func (u *User) ChangePassword(req PasswordChangeRequestFormat, userID uuid.UUID) (err error) {
+	if !checkPasswordHash(req.CurrentPassword, u.Password) {
+		return failure.Unauthorized("Invalid credentials")
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	u.Password = string(bytes)
+	u.UpdatedAt = null.TimeFrom(time.Now())
+	u.UpdatedBy = nuuid.From(userID)
+
+	err = u.Validate()
+
+	return
+}
+
+// SoftDelete marks a User as deleted by setting its "deletedAt" and
+// "deletedBy" properties.
+func (u *User) SoftDelete(userID uuid.UUID) (err error) {
+	if u.IsDeleted() {
+		return failure.Conflict("softDelete", "user", "already marked as deleted")
+	}
+
+	u.DeletedAt = null.TimeFrom(time.Now())
+	u.DeletedBy = nuuid.From(userID)
+
+	return
+}
+
 func (u *User) Update(req UserRequestFormat, userID uuid.UUID) (err error) {
 	u.Name = req.Name
 	u.UpdatedAt = null.TimeFrom(time.Now())
@@ -95,7 +159,27 @@ type UserRequestFormat struct {
 	Username string `json:"username" validate:"required"`
 	Name     string `json:"name" validate:"required"`
 	Password string `json:"password" validate:"required"`
-	Role     string `json:"role" validate:"required"`
+	Role     string `json:"role" validate:"required,oneof=teacher student"`
+}
+
+type PasswordChangeRequestFormat struct {
+	CurrentPassword string `json:"currentPassword" validate:"required"`
+	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+}
+
+// UserEventPayload is the documented payload of every user lifecycle event
+// (user.registered, user.updated, user.deleted and user.password_changed). It
+// deliberately leaves out the password hash.
+type UserEventPayload struct {
+	ID        uuid.UUID  `json:"id"`
+	Username  string     `json:"username"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt null.Time  `json:"updatedAt"`
+	UpdatedBy *uuid.UUID `json:"updatedBy"`
+	DeletedAt null.Time  `json:"deletedAt"`
+	DeletedBy *uuid.UUID `json:"deletedBy"`
 }
 
 type UserResponseFormat struct {
@@ -1,8 +1,10 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 
+	"github.com/evermos/boilerplate-go/event/outbox"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/failure"
 	"github.com/evermos/boilerplate-go/shared/logger"
@@ -12,9 +14,10 @@ import (
 
 var (
 	userQueries = struct {
-		selectUser string
-		insertUser string
-		updateUser string
+		selectUser         string
+		insertUser         string
+		updateUser         string
+		updateUserPassword string
 	}{
 		selectUser: `
 			SELECT
@@ -74,14 +77,24 @@ var (
 			WHERE
 				id = :id
 		`,
+		updateUserPassword: `
+			UPDATE users
+			SET
+				password = :password,
+				updated_at = :updated_at,
+				updated_by = :updated_by
+			WHERE
+				id = :id
+		`,
 	}
 )
 
 type UserRepository interface {
-	CreateUser(user User) (err error)
-	ResolveByUsername(username string) (user User, err error)
-	ResolveByID(id uuid.UUID) (user User, err error)
-	Update(user User) (err error)
+	CreateUser(ctx context.Context, user User, messages ...outbox.Message) (err error)
+	ResolveByUsername(ctx context.Context, username string) (user User, err error)
+	ResolveByID(ctx context.Context, id uuid.UUID) (user User, err error)
+	Update(ctx context.Context, user User, messages ...outbox.Message) (err error)
+	UpdatePassword(ctx context.Context, user User, messages ...outbox.Message) (err error)
 }
 
 type UserRepositoryMySQL struct {
@@ -95,33 +108,40 @@ func ProvideUserRepositoryMySQL(db *infras.MySQLConn) *UserRepositoryMySQL {
 	return s
 }
 
-func (r *UserRepositoryMySQL) CreateUser(user User) (err error) {
-	exists, err := r.ExistsByID(user.ID)
+// CreateUser creates a new User, writing any outbox messages about it in the
+// same transaction.
+func (r *UserRepositoryMySQL) CreateUser(ctx context.Context, user User, messages ...outbox.Message) (err error) {
+	exists, err := r.ExistsByID(ctx, user.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if exists {
 		err = failure.Conflict("create", "userId", "already exists")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	exists, err = r.ExistByUsername(user.Username)
+	exists, err = r.ExistByUsername(ctx, user.Username)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if exists {
 		err = failure.Conflict("create", "username", "already exists")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := r.txCreate(tx, user); err != nil {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txCreate(ctx, tx, user); err != nil {
+			e <- err
+			return
+		}
+
+		if err := outbox.Write(tx, messages...); err != nil {
 			e <- err
 			return
 		}
@@ -130,77 +150,107 @@ func (r *UserRepositoryMySQL) CreateUser(user User) (err error) {
 	})
 }
 
-func (r *UserRepositoryMySQL) ResolveByID(id uuid.UUID) (user User, err error) {
-	err = r.DB.Read.Get(
+func (r *UserRepositoryMySQL) ResolveByID(ctx context.Context, id uuid.UUID) (user User, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
 		&user,
 		userQueries.selectUser+" WHERE id = ?",
 		id.String())
 
 	if err != nil && err == sql.ErrNoRows {
 		err = failure.NotFound("user")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	return
 }
 
-func (r *UserRepositoryMySQL) ResolveByUsername(username string) (user User, err error) {
-	err = r.DB.Read.Get(
+func (r *UserRepositoryMySQL) ResolveByUsername(ctx context.Context, username string) (user User, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
 		&user,
 		userQueries.selectUser+" WHERE username = ?",
 		username)
 
 	if err != nil && err == sql.ErrNoRows {
 		err = failure.NotFound("user")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	return
 }
 
-func (r *UserRepositoryMySQL) ExistsByID(id uuid.UUID) (exists bool, err error) {
-	err = r.DB.Read.Get(
+func (r *UserRepositoryMySQL) ExistsByID(ctx context.Context, id uuid.UUID) (exists bool, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(id) FROM users WHERE id = ?",
 		id.String())
 
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (r *UserRepositoryMySQL) ExistByUsername(username string) (exists bool, err error) {
-	err = r.DB.Read.Get(
+func (r *UserRepositoryMySQL) ExistByUsername(ctx context.Context, username string) (exists bool, err error) {
+	err = r.DB.Read.GetContext(
+		ctx,
 		&exists,
 		"SELECT COUNT(username) FROM users WHERE username = ?",
 		username)
 
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (r *UserRepositoryMySQL) Update(user User) (err error) {
-	exists, err := r.ExistsByID(user.ID)
+// Update updates a User, writing any outbox messages about it in the same
+// transaction.
+func (r *UserRepositoryMySQL) Update(ctx context.Context, user User, messages ...outbox.Message) (err error) {
+	exists, err := r.ExistsByID(ctx, user.ID)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
 	if !exists {
 		err = failure.NotFound("user")
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 
-	return r.DB.WithTransaction(func(tx *sqlx.Tx, e chan error) {
-		if err := r.txUpdate(tx, user); err != nil {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txUpdate(ctx, tx, user); err != nil {
+			e <- err
+			return
+		}
+
+		if err := outbox.Write(tx, messages...); err != nil {
+			e <- err
+			return
+		}
+
+		e <- nil
+	})
+}
+
+// UpdatePassword persists a User's password, writing any outbox messages
+// about it in the same transaction. Update deliberately leaves the password
+// untouched.
+func (r *UserRepositoryMySQL) UpdatePassword(ctx context.Context, user User, messages ...outbox.Message) (err error) {
+	return r.DB.WithTransactionContext(ctx, func(tx *sqlx.Tx, e chan error) {
+		if err := r.txUpdatePassword(ctx, tx, user); err != nil {
+			e <- err
+			return
+		}
+
+		if err := outbox.Write(tx, messages...); err != nil {
 			e <- err
 			return
 		}
@@ -210,33 +260,49 @@ func (r *UserRepositoryMySQL) Update(user User) (err error) {
 }
 
 // Internal Functions
-func (r *UserRepositoryMySQL) txCreate(tx *sqlx.Tx, user User) (err error) {
-	stmt, err := tx.PrepareNamed(userQueries.insertUser)
+func (r *UserRepositoryMySQL) txCreate(ctx context.Context, tx *sqlx.Tx, user User) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, userQueries.insertUser)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, user)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+func (r *UserRepositoryMySQL) txUpdate(ctx context.Context, tx *sqlx.Tx, user User) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, userQueries.updateUser)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user)
+	_, err = stmt.ExecContext(ctx, user)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
 }
 
-func (r *UserRepositoryMySQL) txUpdate(tx *sqlx.Tx, user User) (err error) {
-	stmt, err := tx.PrepareNamed(userQueries.updateUser)
+func (r *UserRepositoryMySQL) txUpdatePassword(ctx context.Context, tx *sqlx.Tx, user User) (err error) {
+	stmt, err := tx.PrepareNamedContext(ctx, userQueries.updateUserPassword)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 		return
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(user)
+	_, err = stmt.ExecContext(ctx, user)
 	if err != nil {
-		logger.ErrorWithStack(err)
+		logger.ErrorWithStackContext(ctx, err)
 	}
 
 	return
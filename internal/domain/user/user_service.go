@@ -1,79 +1,131 @@
 package user
 
 import (
+	"context"
+
 	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/outbox"
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/audit"
 	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/evermos/boilerplate-go/shared/tracing"
 	"github.com/gofrs/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
-	RegisterUser(requestFormat UserRequestFormat) (accessToken string, err error)
-	Login(requestFormat LoginRequestFormat) (accessToken string, err error)
-	ResolveByUsername(username string) (user User, err error)
-	Update(id uuid.UUID, requestFormat UserRequestFormat, userID uuid.UUID) (user User, err error)
+	RegisterUser(ctx context.Context, requestFormat UserRequestFormat, client ClientInfo) (accessToken string, err error)
+	Login(ctx context.Context, requestFormat LoginRequestFormat, client ClientInfo) (accessToken string, err error)
+	ResolveByUsername(ctx context.Context, username string) (user User, err error)
+	Update(ctx context.Context, id uuid.UUID, requestFormat UserRequestFormat, userID uuid.UUID, client ClientInfo) (user User, err error)
+	ChangePassword(ctx context.Context, id uuid.UUID, requestFormat PasswordChangeRequestFormat, sessionID uuid.UUID, client ClientInfo) (err error)
+	Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID, client ClientInfo) (err error)
 }
 
 type UserServiceImpl struct {
-	UserRepository UserRepository
-	Config         *configs.Config
+	UserRepository    UserRepository
+	SessionRepository SessionRepository
+	APIKeyRepository  APIKeyRepository
+	Audit             audit.Recorder
+	Config            *configs.Config
 }
 
-func ProvideUserServiceImpl(userRepository UserRepository, config *configs.Config) *UserServiceImpl {
+func ProvideUserServiceImpl(userRepository UserRepository, sessionRepository SessionRepository, apiKeyRepository APIKeyRepository, auditRecorder audit.Recorder, config *configs.Config) *UserServiceImpl {
 	s := new(UserServiceImpl)
 	s.UserRepository = userRepository
+	s.SessionRepository = sessionRepository
+	s.APIKeyRepository = apiKeyRepository
+	s.Audit = auditRecorder
 	s.Config = config
 
 	return s
 }
 
-func (s *UserServiceImpl) RegisterUser(requestFormat UserRequestFormat) (accessToken string, err error) {
+func (s *UserServiceImpl) RegisterUser(ctx context.Context, requestFormat UserRequestFormat, client ClientInfo) (accessToken string, err error) {
 	var user User
 	user, err = user.NewUserFromRequestFormat(requestFormat)
 	if err != nil {
 		return "", err
 	}
 
-	err = s.UserRepository.CreateUser(user)
+	messages, err := s.lifecycleMessages(ctx, UserRegisteredEventType, user)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.UserRepository.CreateUser(ctx, user, messages...)
 	if err != nil {
+		s.record(ctx, audit.EventTypeRegistration, "", requestFormat.Username, client, audit.OutcomeFailure, audit.Metadata{
+			"reason": err.Error(),
+		})
 		return "", err
 	}
 
-	accessToken, err = s.createToken(user)
+	accessToken, session, err := s.createSession(ctx, user, client)
 	if err != nil {
 		return "", err
 	}
 
+	s.record(ctx, audit.EventTypeRegistration, user.ID.String(), user.ID.String(), client, audit.OutcomeSuccess, audit.Metadata{
+		"username":  user.Username,
+		"role":      user.Role,
+		"sessionId": session.ID.String(),
+	})
+
 	return accessToken, nil
 }
 
-func (s *UserServiceImpl) Login(requestFormat LoginRequestFormat) (accessToken string, err error) {
+func (s *UserServiceImpl) Login(ctx context.Context, requestFormat LoginRequestFormat, client ClientInfo) (accessToken string, err error) {
 	login, err := UserLogin{}.LoginUserFromRequestFormat(requestFormat)
 	if err != nil {
+		countLogin(metrics.ResultFailure, "invalid request")
 		return "", err
 	}
 
-	user, err := s.UserRepository.ResolveByUsername(login.Username)
+	user, err := s.UserRepository.ResolveByUsername(ctx, login.Username)
 	if err != nil {
+		countLogin(metrics.ResultFailure, "unknown user")
+		s.record(ctx, audit.EventTypeLoginFailed, "", login.Username, client, audit.OutcomeFailure, audit.Metadata{
+			"reason": "unknown user",
+		})
 		return "", err
 	}
 
+	if user.IsDeleted() {
+		countLogin(metrics.ResultFailure, "deleted user")
+		s.record(ctx, audit.EventTypeLoginFailed, "", user.ID.String(), client, audit.OutcomeFailure, audit.Metadata{
+			"reason": "deleted user",
+		})
+		return "", failure.Unauthorized("Invalid credentials")
+	}
+
 	isValidPassword := checkPasswordHash(login.Password, user.Password)
 	if !isValidPassword {
+		countLogin(metrics.ResultFailure, "invalid password")
+		s.record(ctx, audit.EventTypeLoginFailed, "", user.ID.String(), client, audit.OutcomeFailure, audit.Metadata{
+			"reason": "invalid password",
+		})
 		return "", failure.Unauthorized("Invalid credentials")
 	}
 
-	accessToken, err = s.createToken(user)
+	accessToken, session, err := s.createSession(ctx, user, client)
 	if err != nil {
+		countLogin(metrics.ResultFailure, "error")
 		return "", failure.InternalError(err)
 	}
 
+	countLogin(metrics.ResultSuccess, "")
+	s.record(ctx, audit.EventTypeLogin, user.ID.String(), user.ID.String(), client, audit.OutcomeSuccess, audit.Metadata{
+		"sessionId": session.ID.String(),
+	})
+
 	return accessToken, nil
 }
 
-func (s *UserServiceImpl) ResolveByUsername(username string) (user User, err error) {
-	user, err = s.UserRepository.ResolveByUsername(username)
+func (s *UserServiceImpl) ResolveByUsername(ctx context.Context, username string) (user User, err error) {
+	user, err = s.UserRepository.ResolveByUsername(ctx, username)
 
 	if user.IsDeleted() {
 		return user, failure.NotFound("user")
@@ -82,8 +134,16 @@ func (s *UserServiceImpl) ResolveByUsername(username string) (user User, err err
 	return
 }
 
-func (s *UserServiceImpl) Update(id uuid.UUID, requestFormat UserRequestFormat, userID uuid.UUID) (user User, err error) {
-	user, err = s.UserRepository.ResolveByID(id)
+func (s *UserServiceImpl) Update(ctx context.Context, id uuid.UUID, requestFormat UserRequestFormat, userID uuid.UUID, client ClientInfo) (user User, err error) {
+	defer func() {
+		outcome, metadata := audit.OutcomeSuccess, audit.Metadata{}
+		if err != nil {
+			outcome, metadata["reason"] = audit.OutcomeFailure, err.Error()
+		}
+		s.record(ctx, audit.EventTypeProfileUpdated, userID.String(), id.String(), client, outcome, metadata)
+	}()
+
+	user, err = s.UserRepository.ResolveByID(ctx, id)
 	if err != nil {
 		return
 	}
@@ -93,7 +153,90 @@ func (s *UserServiceImpl) Update(id uuid.UUID, requestFormat UserRequestFormat,
 		return
 	}
 
-	err = s.UserRepository.Update(user)
+	messages, err := s.lifecycleMessages(ctx, UserUpdatedEventType, user)
+	if err != nil {
+		return
+	}
+
+	err = s.UserRepository.Update(ctx, user, messages...)
+	return
+}
+
+// ChangePassword changes a user's password and revokes every session except
+// the one the change was requested from, and every API key.
+func (s *UserServiceImpl) ChangePassword(ctx context.Context, id uuid.UUID, requestFormat PasswordChangeRequestFormat, sessionID uuid.UUID, client ClientInfo) (err error) {
+	defer func() {
+		outcome, metadata := audit.OutcomeSuccess, audit.Metadata{}
+		if err != nil {
+			outcome, metadata["reason"] = audit.OutcomeFailure, err.Error()
+		}
+		s.record(ctx, audit.EventTypePasswordChanged, id.String(), id.String(), client, outcome, metadata)
+	}()
+
+	user, err := s.UserRepository.ResolveByID(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if user.IsDeleted() {
+		return failure.NotFound("user")
+	}
+
+	err = user.ChangePassword(requestFormat, id)
+	if err != nil {
+		return
+	}
+
+	messages, err := s.lifecycleMessages(ctx, UserPasswordChangedEventType, user)
+	if err != nil {
+		return
+	}
+
+	err = s.UserRepository.UpdatePassword(ctx, user, messages...)
+	if err != nil {
+		return
+	}
+
+	err = s.SessionRepository.RevokeAllByUserID(ctx, user.ID, sessionID)
+	if err != nil {
+		return
+	}
+
+	err = s.APIKeyRepository.RevokeAllByUserID(ctx, user.ID)
+	return
+}
+
+// Delete soft deletes a user and revokes all of their sessions.
+func (s *UserServiceImpl) Delete(ctx context.Context, id uuid.UUID, userID uuid.UUID, client ClientInfo) (err error) {
+	defer func() {
+		outcome, metadata := audit.OutcomeSuccess, audit.Metadata{}
+		if err != nil {
+			outcome, metadata["reason"] = audit.OutcomeFailure, err.Error()
+		}
+		s.record(ctx, audit.EventTypeAccountDeleted, userID.String(), id.String(), client, outcome, metadata)
+	}()
+
+	user, err := s.UserRepository.ResolveByID(ctx, id)
+	if err != nil {
+		return
+	}
+
+	err = user.SoftDelete(userID)
+	if err != nil {
+		return
+	}
+
+	messages, err := s.lifecycleMessages(ctx, UserDeletedEventType, user)
+	if err != nil {
+		return
+	}
+
+	err = s.UserRepository.Update(ctx, user, messages...)
+	if err != nil {
+		return
+	}
+
+	err = s.SessionRepository.RevokeAllByUserID(ctx, user.ID, uuid.Nil)
 	return
 }
 
@@ -102,9 +245,65 @@ func checkPasswordHash(password, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
 
-func (s *UserServiceImpl) createToken(user User) (accessToken string, err error) {
+// countLogin counts a login attempt. Usernames are never used as labels, as
+// metrics are not access controlled like the audit log.
+func countLogin(result string, reason string) {
+	metrics.Logins.WithLabelValues(result, reason).Inc()
+}
+
+// createSession records a new login session and issues a token bound to it.
+func (s *UserServiceImpl) createSession(ctx context.Context, user User, client ClientInfo) (accessToken string, session Session, err error) {
+	session, err = session.NewSession(user.ID, client)
+	if err != nil {
+		return
+	}
+
+	err = s.SessionRepository.Create(ctx, session)
+	if err != nil {
+		return
+	}
+
+	accessToken, err = s.createToken(user, session)
+	return
+}
+
+// record writes an audit event for an action taken from a client.
+func (s *UserServiceImpl) record(ctx context.Context, eventType audit.EventType, actor string, subject string, client ClientInfo, outcome audit.Outcome, metadata audit.Metadata) {
+	s.Audit.Record(ctx, audit.Event{
+		Type:      eventType,
+		Actor:     actor,
+		Subject:   subject,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+		Outcome:   outcome,
+		Metadata:  metadata,
+	})
+}
+
+// lifecycleMessages returns the outbox messages of a user lifecycle event, if
+// enabled. They are written with the change and published by the relay once
+// it is committed, within the trace of ctx.
+func (s *UserServiceImpl) lifecycleMessages(ctx context.Context, eventType string, user User) (messages []outbox.Message, err error) {
+	topic := s.Config.Event.Producer.SNS.Topics.UserLifecycle
+	if !topic.Enabled {
+		return
+	}
+
+	message, err := outbox.NewMessage(UserAggregateType, user.ID.String(), model.PublishRequest{
+		Attributes: tracing.Inject(ctx, nil),
+		Event:      model.NewEvent(eventType, user.ToEventPayload()).WithSubject(user.ID.String()),
+		Topic:      topic.ARN,
+	})
+	if err != nil {
+		return
+	}
+
+	return []outbox.Message{message}, nil
+}
+
+func (s *UserServiceImpl) createToken(user User, session Session) (accessToken string, err error) {
 	jwtService := shared.ProvideJWTService(s.Config.App.Secret)
-	accessToken, err = jwtService.GenerateJWT(user.ID, user.Username, user.Role)
+	accessToken, err = jwtService.GenerateJWT(user.ID, session.ID, user.Username, user.Role)
 	if err != nil {
 		return
 	}
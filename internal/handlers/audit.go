@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/evermos/boilerplate-go/internal/domain/user"
+	"github.com/evermos/boilerplate-go/shared/audit"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+)
+
+// AuditHandler is the HTTP handler for the security audit log.
+type AuditHandler struct {
+	AuditLog       *audit.Log
+	AuthMiddleware *middleware.Authentication
+}
+
+// ProvideAuditHandler is the provider for this handler.
+func ProvideAuditHandler(auditLog *audit.Log, authMiddleware *middleware.Authentication) AuditHandler {
+	return AuditHandler{
+		AuditLog:       auditLog,
+		AuthMiddleware: authMiddleware,
+	}
+}
+
+// Router sets up the router for this handler.
+func (h *AuditHandler) Router(r chi.Router) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
+			r.Use(h.AuthMiddleware.RequireRole(user.RoleAdmin))
+			r.Get("/audit-events", h.ResolveAuditEvents)
+		})
+	})
+}
+
+// ResolveAuditEvents queries the audit log.
+// @Summary Query the audit log.
+// @Description This endpoint lists authentication audit events, newest first.
+// @Tags admin
+// @Security EVMOauthToken
+// @Param type query string false "Filter by event type, e.g. auth.login_failed."
+// @Param actor query string false "Filter by actor."
+// @Param subject query string false "Filter by subject."
+// @Param outcome query string false "Filter by outcome, success or failure."
+// @Param from query string false "Only events at or after this RFC 3339 time."
+// @Param to query string false "Only events before this RFC 3339 time."
+// @Param page query int false "Page number, default 1."
+// @Param pageSize query int false "Page size, default 20, at most 100."
+// @Produce json
+// @Success 200 {object} response.Base{data=audit.Page}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 403 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/admin/audit-events [get]
+func (h *AuditHandler) ResolveAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Type:    audit.EventType(query.Get("type")),
+		Actor:   query.Get("actor"),
+		Subject: query.Get("subject"),
+		Outcome: audit.Outcome(query.Get("outcome")),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	if filter.Page, err = parseIntParam(query.Get("page")); err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	if filter.PageSize, err = parseIntParam(query.Get("pageSize")); err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	page, err := h.AuditLog.Resolve(r.Context(), filter)
+	if err != nil {
+		response.WithError(w, failure.InternalError(err))
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, page)
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func parseIntParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/go-chi/chi"
+)
+
+const (
+	// maxSNSMessageSize is the largest SNS message, 256 KiB, with room for
+	// its envelope.
+	maxSNSMessageSize        = 512 * 1024
+	subscriptionConfirmation = "SubscriptionConfirmation"
+	unsubscribeConfirmation  = "UnsubscribeConfirmation"
+	notification             = "Notification"
+)
+
+// EventHandler is the HTTP handler for events pushed by SNS, for environments
+// that cannot poll SQS.
+type EventHandler struct {
+	Config    *configs.Config
+	Consumers event.Consumers
+	Verifier  *consumer.Verifier
+	Client    *http.Client
+}
+
+// ProvideEventHandler is the provider for this handler.
+func ProvideEventHandler(config *configs.Config, consumers event.Consumers, verifier *consumer.Verifier) EventHandler {
+	return EventHandler{
+		Config:    config,
+		Consumers: consumers,
+		Verifier:  verifier,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Router sets up the router for this handler.
+func (h *EventHandler) Router(r chi.Router) {
+	if !h.Config.Event.Consumer.Push.Enabled {
+		return
+	}
+
+	r.Route("/events", func(r chi.Router) {
+		r.Post("/sns", h.HandleSNS)
+	})
+}
+
+// HandleSNS handles a message pushed by an SNS HTTP subscription.
+// @Summary Receive a message pushed by SNS.
+// @Description This endpoint confirms SNS subscriptions and processes notifications of subscribed topics. Every message must carry a valid SNS signature. Notifications are processed by the same handlers as messages polled from SQS.
+// @Tags events
+// @Param x-amz-sns-message-type header string false "The SNS message type."
+// @Param message body model.SNSMessage true "The SNS message."
+// @Produce json
+// @Success 200 {object} response.Base
+// @Success 204
+// @Failure 400 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /events/sns [post]
+func (h *EventHandler) HandleSNS(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSNSMessageSize))
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	message := model.SNSMessage{}
+	err = json.Unmarshal(body, &message)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	// push endpoints are public, so messages are always verified
+	err = h.Verifier.Verify(message)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	process, ok := h.Consumers.Subscription(message.TopicARN)
+	if !ok {
+		response.WithError(w, failure.NotFound("subscription"))
+		return
+	}
+
+	switch message.Type {
+	case subscriptionConfirmation:
+		err = h.confirmSubscription(r.Context(), message)
+		if err != nil {
+			logger.ErrorWithStackContext(r.Context(), err)
+			response.WithError(w, err)
+			return
+		}
+		response.WithMessage(w, http.StatusOK, "Subscription confirmed")
+	case unsubscribeConfirmation:
+		logger.FromContext(r.Context()).Info().Str("topicArn", message.TopicARN).Msg("SNS subscription was unsubscribed.")
+		response.NoContent(w)
+	case notification:
+		// SNS retries 5xx responses; 4xx failures are permanent
+		ctx, span := consumer.StartSpan(r.Context(), "aws_sns", message.TopicARN, body)
+		err = process(ctx, body)
+		tracing.End(span, err)
+		if err != nil {
+			response.WithError(w, err)
+			return
+		}
+		response.NoContent(w)
+	default:
+		response.WithError(w, failure.BadRequestFromString(fmt.Sprintf("unknown SNS message type %q", message.Type)))
+	}
+}
+
+// confirmSubscription visits the URL confirming a subscription. The URL is
+// covered by the message signature.
+func (h *EventHandler) confirmSubscription(ctx context.Context, message model.SNSMessage) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, message.SubscribeURL, nil)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+
+	resp, err := h.Client.Do(request)
+	if err != nil {
+		return failure.InternalError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return failure.InternalError(fmt.Errorf("confirming SNS subscription: status %d", resp.StatusCode))
+	}
+
+	logger.FromContext(ctx).Info().Str("topicArn", message.TopicARN).Msg("SNS subscription confirmed.")
+	return nil
+}
@@ -0,0 +1,164 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	fooBarBazEvent "github.com/evermos/boilerplate-go/event/domain/foobarbaz"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/shared/failure"
+	"github.com/go-chi/chi"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const topicARN = "arn:aws:sns:ap-southeast-1:000000000000:foo-created"
+
+// snsStub serves a locally generated signing certificate and the
+// subscription confirmation URL, like SNS does.
+type snsStub struct {
+	key       *rsa.PrivateKey
+	server    *httptest.Server
+	confirmed int32
+}
+
+func newSNSStub(t *testing.T) *snsStub {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	stub := &snsStub{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cert.pem", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(certificate)
+	})
+	mux.HandleFunc("/confirm", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stub.confirmed, 1)
+	})
+	stub.server = httptest.NewTLSServer(mux)
+
+	return stub
+}
+
+// message builds and signs an SNS message with signature version 2.
+func (s *snsStub) message(t *testing.T, messageType string, topic string, body string) []byte {
+	message := model.SNSMessage{
+		Type:             messageType,
+		MessageID:        uuid.Must(uuid.NewV4()),
+		TopicARN:         topic,
+		Message:          body,
+		Timestamp:        time.Now().UTC().Format(time.RFC3339Nano),
+		SignatureVersion: "2",
+		SigningCertURL:   s.server.URL + "/cert.pem",
+	}
+
+	fields := []string{"Message", message.Message, "MessageId", message.MessageID.String()}
+	if messageType == "Notification" {
+		fields = append(fields, "Timestamp", message.Timestamp)
+	} else {
+		message.SubscribeURL = s.server.URL + "/confirm"
+		message.Token = "token"
+		fields = append(fields, "SubscribeURL", message.SubscribeURL, "Timestamp", message.Timestamp, "Token", message.Token)
+	}
+	fields = append(fields, "TopicArn", message.TopicARN, "Type", message.Type)
+
+	digest := sha256.Sum256([]byte(strings.Join(fields, "\n") + "\n"))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	message.Signature = base64.StdEncoding.EncodeToString(signature)
+
+	signed, _ := json.Marshal(message)
+	return signed
+}
+
+func TestEventHandler(t *testing.T) {
+	stub := newSNSStub(t)
+	defer stub.server.Close()
+
+	config := &configs.Config{}
+	config.Event.Consumer.Push.Enabled = true
+	config.Event.Consumer.Push.Topics.FooBarBaz.ARN = topicARN
+
+	var processErr error
+	processed := 0
+	consumers := event.Consumers{FooBarBaz: fooBarBazEvent.ConsumerImpl{
+		Config: config,
+		Process: func(ctx context.Context, value []byte) error {
+			processed++
+			return processErr
+		},
+	}}
+
+	handler := handlers.ProvideEventHandler(config, consumers, &consumer.Verifier{
+		AllowedHosts: []string{strings.TrimPrefix(stub.server.URL, "https://")},
+		Client:       stub.server.Client(),
+	})
+	handler.Client = stub.server.Client()
+
+	mux := chi.NewRouter()
+	handler.Router(mux)
+
+	post := func(body []byte) int {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/events/sns", bytes.NewReader(body)))
+		return recorder.Code
+	}
+
+	t.Run("Confirms subscriptions", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, post(stub.message(t, "SubscriptionConfirmation", topicARN, "confirm")))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&stub.confirmed))
+	})
+
+	t.Run("Dispatches notifications to the topic's consumer", func(t *testing.T) {
+		processed = 0
+		assert.Equal(t, http.StatusNoContent, post(stub.message(t, "Notification", topicARN, "{}")))
+		assert.Equal(t, 1, processed)
+	})
+
+	t.Run("Asks SNS to retry only transient failures", func(t *testing.T) {
+		processErr = errors.New("database down")
+		assert.Equal(t, http.StatusInternalServerError, post(stub.message(t, "Notification", topicARN, "{}")))
+
+		processErr = failure.BadRequestFromString("malformed")
+		assert.Equal(t, http.StatusBadRequest, post(stub.message(t, "Notification", topicARN, "{}")))
+		processErr = nil
+	})
+
+	t.Run("Rejects unknown topics and invalid signatures", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, post(stub.message(t, "Notification", "arn:aws:sns:ap-southeast-1:000000000000:other", "{}")))
+
+		tampered := bytes.Replace(stub.message(t, "Notification", topicARN, "{}"), []byte(`"Message":"{}"`), []byte(`"Message":"[]"`), 1)
+		assert.Equal(t, http.StatusBadRequest, post(tampered))
+	})
+}
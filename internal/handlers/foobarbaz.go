@@ -75,7 +75,7 @@ func (h *FooBarBazHandler) CreateFoo(w http.ResponseWriter, r *http.Request) {
 
 	userID, _ := uuid.NewV4() // TODO: read from context
 
-	foo, err := h.FooService.Create(requestFormat, userID)
+	foo, err := h.FooService.Create(r.Context(), requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -107,7 +107,7 @@ func (h *FooBarBazHandler) ResolveFooByID(w http.ResponseWriter, r *http.Request
 
 	withItems, _ := strconv.ParseBool(r.URL.Query().Get("withItems"))
 
-	foo, err := h.FooService.ResolveByID(id, withItems)
+	foo, err := h.FooService.ResolveByID(r.Context(), id, withItems)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -139,7 +139,7 @@ func (h *FooBarBazHandler) SoftDeleteFoo(w http.ResponseWriter, r *http.Request)
 
 	userID, _ := uuid.NewV4() // TODO: read from context
 
-	foo, err := h.FooService.SoftDelete(id, userID)
+	foo, err := h.FooService.SoftDelete(r.Context(), id, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -185,7 +185,7 @@ func (h *FooBarBazHandler) UpdateFoo(w http.ResponseWriter, r *http.Request) {
 
 	userID, _ := uuid.NewV4() // TODO: read from context
 
-	foo, err := h.FooService.Update(id, requestFormat, userID)
+	foo, err := h.FooService.Update(r.Context(), id, requestFormat, userID)
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -15,12 +15,17 @@ import (
 
 type UserHandler struct {
 	UserService    user.UserService
+	APIKeyService  user.APIKeyService
+	SessionService user.SessionService
 	AuthMiddleware *middleware.Authentication
 }
 
-func ProvideUserHandler(userService user.UserService) UserHandler {
+func ProvideUserHandler(userService user.UserService, apiKeyService user.APIKeyService, sessionService user.SessionService, authMiddleware *middleware.Authentication) UserHandler {
 	return UserHandler{
-		UserService: userService,
+		UserService:    userService,
+		APIKeyService:  apiKeyService,
+		SessionService: sessionService,
+		AuthMiddleware: authMiddleware,
 	}
 }
 
@@ -34,10 +39,21 @@ func (h *UserHandler) Router(r chi.Router) {
 	})
 
 	r.Route("/profile", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(h.AuthMiddleware.ClientCredentialWithJWTOrAPIKey)
+			r.With(h.AuthMiddleware.RequireScope(user.APIKeyScopeProfileRead)).Get("/", h.GetProfile)
+			r.With(h.AuthMiddleware.RequireScope(user.APIKeyScopeProfileWrite)).Put("/", h.UpdateProfile)
+		})
+
 		r.Group(func(r chi.Router) {
 			r.Use(h.AuthMiddleware.ClientCredentialWithJWT)
-			r.Get("/", h.GetProfile)
-			r.Put("/", h.UpdateProfile)
+			r.Delete("/", h.DeleteProfile)
+			r.Put("/password", h.ChangePassword)
+			r.Post("/api-keys", h.CreateAPIKey)
+			r.Get("/api-keys", h.ResolveAPIKeys)
+			r.Delete("/api-keys/{id}", h.RevokeAPIKey)
+			r.Get("/sessions", h.ResolveSessions)
+			r.Delete("/sessions/{id}", h.RevokeSession)
 		})
 	})
 }
@@ -57,7 +73,7 @@ func (h *UserHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := h.UserService.RegisterUser(requestFormat)
+	accessToken, err := h.UserService.RegisterUser(r.Context(), requestFormat, clientInfo(r))
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -81,7 +97,7 @@ func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	accessToken, err := h.UserService.Login(requestFormat)
+	accessToken, err := h.UserService.Login(r.Context(), requestFormat, clientInfo(r))
 	if err != nil {
 		response.WithError(w, err)
 		return
@@ -104,9 +120,13 @@ func (h *UserHandler) ValidateAuth(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
-	username := r.Context().Value("username").(string)
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
 
-	user, err := h.UserService.ResolveByUsername(username)
+	user, err := h.UserService.ResolveByUsername(r.Context(), claims.Username)
 	if err != nil {
 		response.WithError(w, failure.NotFound("user"))
 		return
@@ -116,7 +136,12 @@ func (h *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("userID").(uuid.UUID)
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+	userID := claims.UserID
 
 	decoder := json.NewDecoder(r.Body)
 	var requestFormat user.UserRequestFormat
@@ -126,7 +151,7 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.UserService.Update(userID, requestFormat, userID)
+	user, err := h.UserService.Update(r.Context(), userID, requestFormat, userID, clientInfo(r))
 	if err != nil {
 		response.WithError(w, failure.InternalError(err))
 		return
@@ -134,3 +159,255 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 
 	response.WithJSON(w, http.StatusOK, user)
 }
+
+// ChangePassword changes the current user's password.
+// @Summary Change the current user's password.
+// @Description This endpoint changes the password and logs the user out of every other session.
+// @Tags profile
+// @Security EVMOauthToken
+// @Param password body user.PasswordChangeRequestFormat true "The current and new password."
+// @Produce json
+// @Success 200 {object} response.Base
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/password [put]
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var requestFormat user.PasswordChangeRequestFormat
+	err := decoder.Decode(&requestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(requestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = h.UserService.ChangePassword(r.Context(), claims.UserID, requestFormat, claims.SessionID, clientInfo(r))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithMessage(w, http.StatusOK, "Password changed")
+}
+
+// DeleteProfile deletes the current user's account.
+// @Summary Delete the current user's account.
+// @Description This endpoint soft deletes the account and revokes all of its sessions.
+// @Tags profile
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile [delete]
+func (h *UserHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	err := h.UserService.Delete(r.Context(), claims.UserID, claims.UserID, clientInfo(r))
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithMessage(w, http.StatusOK, "Account deleted")
+}
+
+// CreateAPIKey creates a new API key for the current user.
+// @Summary Create a new API key.
+// @Description This endpoint creates a new API key. The key is only shown in this response.
+// @Tags profile/api-keys
+// @Security EVMOauthToken
+// @Param apiKey body user.APIKeyRequestFormat true "The API key to be created."
+// @Produce json
+// @Success 201 {object} response.Base{data=user.APIKeyCreatedResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/api-keys [post]
+func (h *UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var requestFormat user.APIKeyRequestFormat
+	err := decoder.Decode(&requestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	err = shared.GetValidator().Struct(requestFormat)
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	apiKey, key, err := h.APIKeyService.Create(r.Context(), requestFormat, claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusCreated, user.APIKeyCreatedResponseFormat{
+		APIKeyResponseFormat: apiKey.ToResponseFormat(),
+		Key:                  key,
+	})
+}
+
+// ResolveAPIKeys lists the current user's API keys.
+// @Summary List API keys.
+// @Description This endpoint lists the current user's API keys without the keys themselves.
+// @Tags profile/api-keys
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=[]user.APIKeyResponseFormat}
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/api-keys [get]
+func (h *UserHandler) ResolveAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	apiKeys, err := h.APIKeyService.ResolveByUserID(r.Context(), claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, apiKeys)
+}
+
+// RevokeAPIKey revokes one of the current user's API keys.
+// @Summary Revoke an API key.
+// @Description This endpoint revokes an API key so it can no longer be used.
+// @Tags profile/api-keys
+// @Security EVMOauthToken
+// @Param id path string true "The API key's identifier."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.APIKeyResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/api-keys/{id} [delete]
+func (h *UserHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	id, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	apiKey, err := h.APIKeyService.Revoke(r.Context(), id, claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, apiKey)
+}
+
+// ResolveSessions lists the current user's active login sessions.
+// @Summary List active sessions.
+// @Description This endpoint lists the devices the current user is logged in from.
+// @Tags profile/sessions
+// @Security EVMOauthToken
+// @Produce json
+// @Success 200 {object} response.Base{data=[]user.SessionResponseFormat}
+// @Failure 401 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/sessions [get]
+func (h *UserHandler) ResolveSessions(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	sessions, err := h.SessionService.ResolveActiveByUserID(r.Context(), claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	responseFormat := make([]user.SessionResponseFormat, 0)
+	for _, session := range sessions {
+		sessionResponse := session.ToResponseFormat()
+		sessionResponse.Current = session.ID == claims.SessionID
+		responseFormat = append(responseFormat, sessionResponse)
+	}
+
+	response.WithJSON(w, http.StatusOK, responseFormat)
+}
+
+// RevokeSession logs the current user out of one of their sessions.
+// @Summary Revoke a session.
+// @Description This endpoint revokes a session so tokens issued for it are no longer accepted.
+// @Tags profile/sessions
+// @Security EVMOauthToken
+// @Param id path string true "The session's identifier."
+// @Produce json
+// @Success 200 {object} response.Base{data=user.SessionResponseFormat}
+// @Failure 400 {object} response.Base
+// @Failure 404 {object} response.Base
+// @Failure 409 {object} response.Base
+// @Failure 500 {object} response.Base
+// @Router /v1/profile/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		response.WithError(w, failure.Unauthorized("Token not authorized"))
+		return
+	}
+
+	id, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		response.WithError(w, failure.BadRequest(err))
+		return
+	}
+
+	session, err := h.SessionService.Revoke(r.Context(), id, claims.UserID)
+	if err != nil {
+		response.WithError(w, err)
+		return
+	}
+
+	response.WithJSON(w, http.StatusOK, session)
+}
+
+// clientInfo describes the device a request was sent from.
+func clientInfo(r *http.Request) user.ClientInfo {
+	return user.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: middleware.ClientIP(r),
+	}
+}
@@ -0,0 +1,176 @@
+package janitor
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultBatchSize       = 500
+	defaultIntervalSeconds = 300
+)
+
+// Target is a table whose rows expire and should be purged once they do.
+type Target struct {
+	Table        string
+	ExpiryColumn string
+}
+
+// Targets lists every table the janitor keeps clean. Tables holding expiring
+// tokens or records should be registered here.
+var Targets = []Target{
+	{Table: "oauth_access_tokens", ExpiryColumn: "expires"},
+	{Table: "api_keys", ExpiryColumn: "expires_at"},
+	{Table: "sessions", ExpiryColumn: "expires_at"},
+	{Table: "processed_messages", ExpiryColumn: "expires_at"},
+}
+
+var counters = struct {
+	runs     *expvar.Int
+	failures *expvar.Int
+	deleted  *expvar.Map
+}{
+	runs:     expvar.NewInt("janitor.runs"),
+	failures: expvar.NewInt("janitor.failures"),
+	deleted:  expvar.NewMap("janitor.deleted"),
+}
+
+// Janitor periodically deletes expired rows from the registered targets.
+type Janitor struct {
+	DB     *infras.MySQLConn
+	Config *configs.Config
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// ProvideJanitor is the provider for Janitor.
+func ProvideJanitor(db *infras.MySQLConn, config *configs.Config) *Janitor {
+	return &Janitor{
+		DB:     db,
+		Config: config,
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start runs the janitor in the background on the configured interval.
+func (j *Janitor) Start() {
+	if !j.Config.Janitor.Enabled {
+		log.Info().Msg("Janitor is disabled.")
+		return
+	}
+
+	interval := j.interval()
+	log.Info().Dur("interval", interval).Int("batchSize", j.batchSize()).Msg("Janitor started.")
+
+	j.done = make(chan struct{})
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.RunOnce()
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the janitor and waits for a purge in progress to finish.
+func (j *Janitor) Stop() {
+	j.once.Do(func() {
+		close(j.stop)
+	})
+
+	if j.done != nil {
+		<-j.done
+		log.Info().Msg("Janitor stopped.")
+	}
+}
+
+// RunOnce purges all expired rows from every target and returns the number of
+// rows deleted per table.
+func (j *Janitor) RunOnce() (deleted map[string]int64, err error) {
+	counters.runs.Add(1)
+	deleted = make(map[string]int64)
+	now := time.Now()
+
+	for _, target := range Targets {
+		count, purgeErr := j.purge(target, now)
+		deleted[target.Table] = count
+		counters.deleted.Add(target.Table, count)
+		metrics.JanitorDeletedRows.WithLabelValues(target.Table).Add(float64(count))
+
+		if purgeErr != nil {
+			counters.failures.Add(1)
+			metrics.JanitorErrors.WithLabelValues(target.Table).Inc()
+			logger.ErrorWithStack(purgeErr)
+			err = purgeErr
+			continue
+		}
+
+		log.Info().Str("table", target.Table).Int64("deleted", count).Msg("Janitor purged expired rows.")
+	}
+
+	return
+}
+
+// purge deletes expired rows in batches so that a large backlog never holds
+// long-running locks on the table.
+func (j *Janitor) purge(target Target, now time.Time) (deleted int64, err error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE %s < ? LIMIT ?",
+		target.Table,
+		target.ExpiryColumn)
+	batchSize := j.batchSize()
+
+	for {
+		select {
+		case <-j.stop:
+			return
+		default:
+		}
+
+		result, err := j.DB.Write.Exec(query, now, batchSize)
+		if err != nil {
+			return deleted, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += affected
+		if affected < int64(batchSize) {
+			return deleted, nil
+		}
+	}
+}
+
+func (j *Janitor) batchSize() int {
+	if j.Config.Janitor.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return j.Config.Janitor.BatchSize
+}
+
+func (j *Janitor) interval() time.Duration {
+	if j.Config.Janitor.IntervalSeconds <= 0 {
+		return defaultIntervalSeconds * time.Second
+	}
+	return time.Duration(j.Config.Janitor.IntervalSeconds) * time.Second
+}
@@ -0,0 +1,76 @@
+package janitor_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/internal/janitor"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJanitor(t *testing.T) {
+	t.Run("Purges in batches until exhausted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		targets := janitor.Targets
+		defer func() { janitor.Targets = targets }()
+		janitor.Targets = []janitor.Target{{Table: "oauth_access_tokens", ExpiryColumn: "expires"}}
+
+		config := &configs.Config{}
+		config.Janitor.BatchSize = 2
+
+		query := "DELETE FROM oauth_access_tokens WHERE expires < \\? LIMIT \\?"
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		before := testutil.ToFloat64(metrics.JanitorDeletedRows.WithLabelValues("oauth_access_tokens"))
+
+		j := janitor.ProvideJanitor(infras.OpenMock(db), config)
+		deleted, err := j.RunOnce()
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), deleted["oauth_access_tokens"])
+		assert.Equal(t, before+5, testutil.ToFloat64(metrics.JanitorDeletedRows.WithLabelValues("oauth_access_tokens")))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Counts failed purges by table", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		targets := janitor.Targets
+		defer func() { janitor.Targets = targets }()
+		janitor.Targets = []janitor.Target{{Table: "sessions", ExpiryColumn: "expires_at"}}
+
+		mock.ExpectExec("DELETE FROM sessions WHERE expires_at < \\? LIMIT \\?").
+			WillReturnError(errors.New("lock wait timeout"))
+
+		before := testutil.ToFloat64(metrics.JanitorErrors.WithLabelValues("sessions"))
+
+		j := janitor.ProvideJanitor(infras.OpenMock(db), &configs.Config{})
+		_, err = j.RunOnce()
+
+		assert.Error(t, err)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.JanitorErrors.WithLabelValues("sessions")))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stop without start returns", func(t *testing.T) {
+		db, _, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		j := janitor.ProvideJanitor(infras.OpenMock(db), &configs.Config{})
+		j.Start()
+		j.Stop()
+	})
+}
@@ -4,33 +4,106 @@ package main
 //go:generate go run github.com/google/wire/cmd/wire
 
 import (
+	"context"
+	"flag"
+
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	transportHTTP "github.com/evermos/boilerplate-go/transport/http"
+	"github.com/rs/zerolog/log"
 )
 
 var config *configs.Config
 
+// Runtime modes, selected with the -mode flag.
+const (
+	modeHTTP   = "http"
+	modeWorker = "worker"
+	modeAll    = "all"
+)
+
+var (
+	janitorOnce = flag.Bool("janitor-once", false, "purge expired tokens once and exit, for use in cron jobs")
+	mode        = flag.String("mode", modeHTTP, "what to run: http (the API), worker (the event consumers) or all")
+)
+
 //@securityDefinitions.apikey EVMOauthToken
 //@in header
 //@name Authorization
 func main() {
+	flag.Parse()
+
 	// Initialize logger
 	logger.InitLogger()
 
 	// Initialize config
 	config = configs.Get()
 
-	// Set desired log level
+	// Set desired log format and level
+	logger.SetLogFormat(config)
 	logger.SetLogLevel(config)
 
-	// Wire everything up
-	http := InitializeService()
+	// Open the database connections shared by everything
+	db := InitializeMySQLConn()
+
+	// Run the janitor in one-shot mode if requested
+	if *janitorOnce {
+		if _, err := InitializeJanitor(db).RunOnce(); err != nil {
+			log.Fatal().Err(err).Msg("Janitor failed purging expired tokens.")
+		}
+		return
+	}
+
+	if *mode != modeHTTP && *mode != modeWorker && *mode != modeAll {
+		log.Fatal().Str("mode", *mode).Msg("Unknown mode, expecting http, worker or all.")
+	}
+
+	// Set up tracing before anything starts spans
+	shutdownTracing, err := tracing.Init(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed setting up tracing.")
+	}
+
+	// Wire everything up, workers only need the health check
+	var http *transportHTTP.HTTP
+	if *mode == modeWorker {
+		http = InitializeHealth(db)
+	} else {
+		http = InitializeService(db)
+	}
+
+	if *mode == modeHTTP || *mode == modeAll {
+		// Start the expired token janitor, stopping it on shutdown
+		janitor := InitializeJanitor(db)
+		janitor.Start()
+		http.OnCleanup(janitor.Stop)
+
+		// Start the outbox relay, stopping it on shutdown
+		relay := InitializeOutboxRelay(db)
+		relay.Start()
+		http.OnCleanup(relay.Stop)
+	}
 
-	// consumers := InitializeEvent()
+	if *mode == modeWorker || *mode == modeAll {
+		// Start consumers, letting them finish in-flight messages on shutdown
+		consumers := InitializeEvent(db)
+		consumers.Start()
+		http.OnCleanup(consumers.Stop)
+		http.OnHealthCheck("consumers", consumers.Health)
+	}
 
-	// Start consumers
-	// consumers.Start()
+	// Flush pending spans once everything else has stopped
+	http.OnCleanup(func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("Failed flushing spans.")
+		}
+	})
 
 	// Run server
+	if *mode == modeWorker {
+		http.SetupAndServeHealth()
+		return
+	}
 	http.SetupAndServe()
 }
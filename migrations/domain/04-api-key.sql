@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS `api_keys`;
+
+CREATE TABLE api_keys (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    prefix CHAR(8) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    PRIMARY KEY (id),
+    UNIQUE idx_api_keys_1 (prefix),
+    INDEX idx_api_keys_2 (user_id),
+    INDEX idx_api_keys_3 (expires_at)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
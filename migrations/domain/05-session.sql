@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS `sessions`;
+
+CREATE TABLE sessions (
+    id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    created_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    PRIMARY KEY (id),
+    INDEX idx_sessions_1 (user_id),
+    INDEX idx_sessions_2 (expires_at)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS `audit_events`;
+
+CREATE TABLE audit_events (
+    id CHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(512) NOT NULL,
+    outcome ENUM('success', 'failure') NOT NULL,
+    metadata TEXT NOT NULL,
+    occurred_at DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_audit_events_1 (occurred_at),
+    INDEX idx_audit_events_2 (event_type, occurred_at),
+    INDEX idx_audit_events_3 (actor, occurred_at),
+    INDEX idx_audit_events_4 (subject, occurred_at)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
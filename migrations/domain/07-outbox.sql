@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS `outbox_messages`;
+
+CREATE TABLE outbox_messages (
+    seq BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+    id CHAR(36) NOT NULL,
+    aggregate_type VARCHAR(64) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    event_type VARCHAR(255) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    message_group_id VARCHAR(128) NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    created_at DATETIME NOT NULL,
+    available_at DATETIME NOT NULL,
+    sent_at DATETIME NULL,
+    failed_at DATETIME NULL,
+    PRIMARY KEY (seq),
+    UNIQUE KEY uq_outbox_messages_1 (id),
+    INDEX idx_outbox_messages_1 (sent_at, failed_at, seq),
+    INDEX idx_outbox_messages_2 (aggregate_type, aggregate_id, seq)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
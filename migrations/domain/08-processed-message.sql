@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS `processed_messages`;
+
+CREATE TABLE processed_messages (
+    message_key VARCHAR(191) NOT NULL,
+    status ENUM('processing', 'done') NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (message_key),
+    INDEX idx_processed_messages_1 (expires_at)
+) ENGINE=InnoDB
+DEFAULT CHARSET=utf8;
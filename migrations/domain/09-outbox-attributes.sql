@@ -0,0 +1,2 @@
+ALTER TABLE outbox_messages
+    ADD COLUMN attributes TEXT NULL AFTER message_group_id;
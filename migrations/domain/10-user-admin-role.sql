@@ -0,0 +1 @@
+ALTER TABLE users MODIFY role ENUM('teacher', 'student', 'admin');
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// EventType is the kind of authentication event being recorded.
+type EventType string
+
+const (
+	// EventTypeLogin indicates a successful login.
+	EventTypeLogin EventType = "auth.login"
+	// EventTypeLoginFailed indicates a rejected login attempt.
+	EventTypeLoginFailed EventType = "auth.login_failed"
+	// EventTypeRegistration indicates a new user registering.
+	EventTypeRegistration EventType = "auth.registration"
+	// EventTypeProfileUpdated indicates a user updating their profile.
+	EventTypeProfileUpdated EventType = "auth.profile_updated"
+	// EventTypePasswordChanged indicates a user changing their password.
+	EventTypePasswordChanged EventType = "auth.password_changed"
+	// EventTypeAccountDeleted indicates a user deleting their account.
+	EventTypeAccountDeleted EventType = "auth.account_deleted"
+	// EventTypeTokenIssued indicates an OAuth access token being issued.
+	EventTypeTokenIssued EventType = "auth.token_issued"
+	// EventTypeAccessDenied indicates a request rejected by an auth middleware.
+	EventTypeAccessDenied EventType = "auth.access_denied"
+)
+
+// Outcome is whether the audited action succeeded.
+type Outcome string
+
+const (
+	// OutcomeSuccess indicates the action succeeded.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeFailure indicates the action failed.
+	OutcomeFailure Outcome = "failure"
+)
+
+// Metadata holds event-specific details. It must never contain secrets such
+// as passwords or tokens.
+type Metadata map[string]interface{}
+
+// Event is a single entry in the audit log. Actor is who performed the action
+// and Subject is who or what it was performed on, e.g. a user ID, a client ID,
+// or the username of a failed login.
+type Event struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	Type       EventType `db:"event_type" json:"type"`
+	Actor      string    `db:"actor" json:"actor"`
+	Subject    string    `db:"subject" json:"subject"`
+	IPAddress  string    `db:"ip_address" json:"ipAddress"`
+	UserAgent  string    `db:"user_agent" json:"userAgent"`
+	Outcome    Outcome   `db:"outcome" json:"outcome"`
+	Metadata   Metadata  `db:"metadata" json:"metadata"`
+	OccurredAt time.Time `db:"occurred_at" json:"occurredAt"`
+}
+
+// Scan implements the Scanner interface.
+func (m *Metadata) Scan(value interface{}) error {
+	switch x := value.(type) {
+	case []byte:
+		return json.Unmarshal(x, m)
+	case string:
+		return json.Unmarshal([]byte(x), m)
+	default:
+		*m = Metadata{}
+		return nil
+	}
+}
+
+// Value implements the driver Valuer interface.
+func (m Metadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(m)
+	return string(value), err
+}
+
+// Recorder records audit events. Implementations must not fail the caller:
+// errors are logged with the logger of ctx instead of returned.
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+// NopRecorder discards every event.
+type NopRecorder struct{}
+
+// Record implements Recorder.
+func (NopRecorder) Record(ctx context.Context, event Event) {}
+
+// Filter narrows down a query on the audit log.
+type Filter struct {
+	Type     EventType
+	Actor    string
+	Subject  string
+	Outcome  Outcome
+	From     *time.Time
+	To       *time.Time
+	Page     int
+	PageSize int
+}
+
+// Page is a single page of audit events.
+type Page struct {
+	Events   []Event `json:"events"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"pageSize"`
+	Total    int     `json:"total"`
+}
@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/event/model"
+	"github.com/evermos/boilerplate-go/event/producer"
+	"github.com/evermos/boilerplate-go/infras"
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/gofrs/uuid"
+)
+
+const (
+	defaultPageSize    = 20
+	maxPageSize        = 100
+	maxActorLength     = 255
+	maxSubjectLength   = 255
+	maxIPAddressLength = 45
+	maxUserAgentLength = 512
+)
+
+var (
+	// AuditEventType is the event type used when publishing audit events.
+	AuditEventType = "evm.boilerplate-go.audit"
+
+	auditQueries = struct {
+		selectEvent string
+		countEvent  string
+		insertEvent string
+	}{
+		selectEvent: `
+			SELECT
+				id,
+				event_type,
+				actor,
+				subject,
+				ip_address,
+				user_agent,
+				outcome,
+				metadata,
+				occurred_at
+			FROM audit_events
+		`,
+		countEvent: `
+			SELECT COUNT(id) FROM audit_events
+		`,
+		insertEvent: `
+			INSERT INTO audit_events (
+				id,
+				event_type,
+				actor,
+				subject,
+				ip_address,
+				user_agent,
+				outcome,
+				metadata,
+				occurred_at
+			) VALUES (
+				:id,
+				:event_type,
+				:actor,
+				:subject,
+				:ip_address,
+				:user_agent,
+				:outcome,
+				:metadata,
+				:occurred_at
+			)
+		`,
+	}
+)
+
+// Log is the append-only, MySQL-backed audit log. Events are only ever
+// inserted and queried, never updated or deleted.
+type Log struct {
+	DB       *infras.MySQLConn
+	Producer producer.Producer
+	Config   *configs.Config
+}
+
+// ProvideLog is the provider for Log.
+func ProvideLog(db *infras.MySQLConn, producer producer.Producer, config *configs.Config) *Log {
+	return &Log{
+		DB:       db,
+		Producer: producer,
+		Config:   config,
+	}
+}
+
+// Record persists an event and, if enabled, publishes it.
+func (l *Log) Record(ctx context.Context, event Event) {
+	if event.ID == uuid.Nil {
+		event.ID, _ = uuid.NewV4()
+	}
+
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if event.Metadata == nil {
+		event.Metadata = Metadata{}
+	}
+
+	// values come from requests, so they are cut to fit their columns
+	event.Actor = truncate(event.Actor, maxActorLength)
+	event.Subject = truncate(event.Subject, maxSubjectLength)
+	event.IPAddress = truncate(event.IPAddress, maxIPAddressLength)
+	event.UserAgent = truncate(event.UserAgent, maxUserAgentLength)
+
+	if _, err := l.DB.Write.NamedExecContext(ctx, auditQueries.insertEvent, event); err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+
+	topic := l.Config.Event.Producer.SNS.Topics.AuditEvent
+	if topic.Enabled {
+		err := l.Producer.Publish(model.PublishRequest{
+			Attributes: tracing.Inject(ctx, nil),
+			Event:      model.NewEvent(AuditEventType, event).WithSubject(event.Subject),
+			Topic:      topic.ARN,
+		})
+		if err != nil {
+			logger.ErrorWithStackContext(ctx, err)
+		}
+	}
+}
+
+// Resolve returns a page of events matching a filter, newest first.
+func (l *Log) Resolve(ctx context.Context, filter Filter) (page Page, err error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+
+	if filter.PageSize < 1 {
+		filter.PageSize = defaultPageSize
+	}
+
+	if filter.PageSize > maxPageSize {
+		filter.PageSize = maxPageSize
+	}
+
+	where, args := composeWhere(filter)
+
+	page = Page{
+		Events:   make([]Event, 0),
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+	}
+
+	err = l.DB.Read.GetContext(ctx, &page.Total, auditQueries.countEvent+where, args...)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+		return
+	}
+
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	err = l.DB.Read.SelectContext(
+		ctx,
+		&page.Events,
+		auditQueries.selectEvent+where+" ORDER BY occurred_at DESC LIMIT ? OFFSET ?",
+		args...)
+	if err != nil {
+		logger.ErrorWithStackContext(ctx, err)
+	}
+
+	return
+}
+
+// truncate cuts s to at most length characters.
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length])
+}
+
+// composeWhere composes the WHERE clause for a filter.
+func composeWhere(filter Filter) (where string, args []interface{}) {
+	conditions := []string{}
+
+	if filter.Type != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, filter.Type)
+	}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+
+	if filter.Subject != "" {
+		conditions = append(conditions, "subject = ?")
+		args = append(args, filter.Subject)
+	}
+
+	if filter.Outcome != "" {
+		conditions = append(conditions, "outcome = ?")
+		args = append(args, filter.Outcome)
+	}
+
+	if filter.From != nil {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, *filter.From)
+	}
+
+	if filter.To != nil {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, *filter.To)
+	}
+
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return
+}
@@ -9,13 +9,35 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
+// TokenLifetime is how long a generated JWT stays valid.
+const TokenLifetime = time.Hour
+
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Username string    `json:"username"`
-	Role     string    `json:"role"`
+	UserID    uuid.UUID `json:"user_id"`
+	SessionID uuid.UUID `json:"sid"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes,omitempty"`
 	jwt.StandardClaims
 }
 
+// HasScope checks whether the claims grant a scope. Claims of a login
+// session, i.e. with a SessionID, are allowed everything; any other claims,
+// e.g. of an API key, only the scopes they list.
+func (c *Claims) HasScope(scope string) bool {
+	if c.SessionID != uuid.Nil {
+		return true
+	}
+
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
 type JWTService struct {
 	Secret string
 }
@@ -26,13 +48,14 @@ func ProvideJWTService(secret string) *JWTService {
 	}
 }
 
-func (j *JWTService) GenerateJWT(userID uuid.UUID, username string, role string) (string, error) {
+func (j *JWTService) GenerateJWT(userID uuid.UUID, sessionID uuid.UUID, username string, role string) (string, error) {
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		SessionID: sessionID,
+		Username:  username,
+		Role:      role,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 1).Unix(),
+			ExpiresAt: time.Now().Add(TokenLifetime).Unix(),
 			Issuer:    "bootcamp",
 		},
 	}
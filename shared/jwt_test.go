@@ -0,0 +1,23 @@
+package shared_test
+
+import (
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimsHasScope(t *testing.T) {
+	t.Run("Login sessions are allowed everything", func(t *testing.T) {
+		claims := &shared.Claims{SessionID: uuid.Must(uuid.NewV4())}
+		assert.True(t, claims.HasScope("profile:write"))
+	})
+
+	t.Run("Other claims only their scopes", func(t *testing.T) {
+		claims := &shared.Claims{Scopes: []string{"profile:read"}}
+		assert.True(t, claims.HasScope("profile:read"))
+		assert.False(t, claims.HasScope("profile:write"))
+		assert.False(t, (&shared.Claims{}).HasScope("profile:read"))
+	})
+}
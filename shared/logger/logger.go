@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"time"
 
@@ -10,6 +11,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// FormatConsole writes human-readable logs, e.g. for development.
+	FormatConsole = "console"
+	// FormatJSON writes a JSON object per line, e.g. for a log pipeline.
+	FormatJSON = "json"
+)
+
 // InitLogger initializes the logger
 func InitLogger() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
@@ -22,11 +30,56 @@ func InitLogger() {
 	log.Trace().Msg("Zerolog initialized.")
 }
 
+// SetLogFormat sets the output format specified in env var: console (the
+// default) or json.
+func SetLogFormat(config *configs.Config) {
+	format := config.Server.LogFormat
+	switch format {
+	case FormatJSON:
+		log.Logger = log.Output(os.Stdout)
+	case FormatConsole:
+	case "":
+		format = FormatConsole
+	default:
+		log.Warn().Str("logformat", format).Msg("Unknown log format, using console.")
+		return
+	}
+	log.Trace().Str("logformat", format).Msg("Desired log format detected.")
+}
+
 // ErrorWithStack logs and error and its stack trace with custom formatting.
 func ErrorWithStack(err error) {
 	log.Error().Msgf("%+v", errors.WithStack(err))
 }
 
+// ErrorWithStackContext logs an error and its stack trace like
+// ErrorWithStack, with the logger of ctx.
+func ErrorWithStackContext(ctx context.Context, err error) {
+	FromContext(ctx).Error().Msgf("%+v", errors.WithStack(err))
+}
+
+// NewContext returns a copy of ctx carrying l, e.g. the logger of a request.
+func NewContext(ctx context.Context, l zerolog.Logger) context.Context {
+	return l.WithContext(ctx)
+}
+
+// FromContext returns the logger of ctx, or the global logger if ctx has
+// none.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
+
+// UpdateContext adds fields to the logger of ctx, e.g. once the user of a
+// request is known. It does nothing if ctx has no logger of its own.
+func UpdateContext(ctx context.Context, update func(c zerolog.Context) zerolog.Context) {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		l.UpdateContext(update)
+	}
+}
+
 // SetLogLevel sets the desired log level specified in env var.
 func SetLogLevel(config *configs.Config) {
 	level, err := zerolog.ParseLevel(config.Server.LogLevel)
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var databases = newDBCollector()
+
+// RegisterDB exposes the connection pool stats of a database under a name,
+// e.g. read or write. A pool registered under a name already taken replaces
+// the one registered before.
+func RegisterDB(name string, db *sql.DB) {
+	databases.register(name, db)
+}
+
+// dbCollector collects sql.DBStats of the registered pools.
+type dbCollector struct {
+	mu    sync.Mutex
+	pools map[string]*sql.DB
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func newDBCollector() *dbCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, []string{"db"}, nil)
+	}
+
+	return &dbCollector{
+		pools:             make(map[string]*sql.DB),
+		maxOpen:           desc("db_max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("db_open_connections", "Established connections, in use or idle."),
+		inUse:             desc("db_in_use_connections", "Connections currently in use."),
+		idle:              desc("db_idle_connections", "Idle connections."),
+		waitCount:         desc("db_wait_count_total", "Connections waited for."),
+		waitDuration:      desc("db_wait_duration_seconds_total", "Time spent waiting for a connection."),
+		maxIdleClosed:     desc("db_max_idle_closed_total", "Connections closed due to the idle connection limit."),
+		maxLifetimeClosed: desc("db_max_lifetime_closed_total", "Connections closed due to their maximum lifetime."),
+	}
+}
+
+func (c *dbCollector) register(name string, db *sql.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pools[name] = db
+}
+
+// Describe implements prometheus.Collector.
+func (c *dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect implements prometheus.Collector.
+func (c *dbCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, db := range c.pools {
+		stats := db.Stats()
+		ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections), name)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), name)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), name)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), name)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), name)
+		ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), name)
+		ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), name)
+	}
+}
@@ -0,0 +1,34 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterDB(t *testing.T) {
+	first, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer first.Close()
+	second, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer second.Close()
+
+	first.SetMaxOpenConns(10)
+	second.SetMaxOpenConns(5)
+	metrics.RegisterDB("test", first)
+	metrics.RegisterDB("test", second)
+
+	expected := `
+# HELP db_max_open_connections Maximum number of open connections to the database.
+# TYPE db_max_open_connections gauge
+db_max_open_connections{db="test"} 5
+`
+	err = testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected), "db_max_open_connections")
+	assert.NoError(t, err)
+}
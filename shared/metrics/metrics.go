@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Results of the operations counted below.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	// ResultSkipped is the result of a consumed event without a handler.
+	ResultSkipped = "skipped"
+)
+
+var (
+	// HTTPRequests counts requests by method, route pattern and status.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration is the latency of requests by method, route pattern
+	// and status.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route pattern and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// Logins counts login attempts by result and, for failures, reason. It
+	// never identifies the user.
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "user_logins_total",
+		Help: "Login attempts by result and reason.",
+	}, []string{"result", "reason"})
+
+	// EventsPublished counts published events by type and result.
+	EventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Published events by type and result.",
+	}, []string{"type", "result"})
+
+	// EventsConsumed counts consumed events by type and result.
+	EventsConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_consumed_total",
+		Help: "Consumed events by type and result.",
+	}, []string{"type", "result"})
+
+	// JanitorDeletedRows counts expired rows purged by the janitor by table.
+	JanitorDeletedRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_deleted_rows_total",
+		Help: "Expired rows purged by the janitor by table.",
+	}, []string{"table"})
+
+	// JanitorErrors counts failed purges by table.
+	JanitorErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_errors_total",
+		Help: "Failed janitor purges by table.",
+	}, []string{"table"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		Logins,
+		EventsPublished,
+		EventsConsumed,
+		JanitorDeletedRows,
+		JanitorErrors,
+		databases,
+	)
+}
+
+// Handler serves every metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Result returns the result label of an operation that failed with err.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
@@ -1,6 +1,9 @@
 package oauth
 
 import (
+	"context"
+
+	"github.com/evermos/boilerplate-go/shared/audit"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -17,6 +20,10 @@ type Token struct {
 }
 
 func New(db *sqlx.DB, config Config) *Token {
+	if config.Audit == nil {
+		config.Audit = audit.NopRecorder{}
+	}
+
 	return &Token{
 		config:          config,
 		tokenRepository: NewTokenStore(db),
@@ -26,11 +33,13 @@ func New(db *sqlx.DB, config Config) *Token {
 type Config struct {
 	Expiration  int64
 	ClientScope []string
+	// Audit records issued and rejected tokens, defaults to discarding them.
+	Audit audit.Recorder
 }
 
 // Create is function to store NewToken into database
-func (t *Token) Create(credential Credential) (*TokenResponse, error) {
-	grant, err := NewGrant(t.tokenRepository, t.config).Create(credential)
+func (t *Token) Create(ctx context.Context, credential Credential) (*TokenResponse, error) {
+	grant, err := NewGrant(t.tokenRepository, t.config).Create(ctx, credential)
 	if err != nil {
 		return &TokenResponse{}, err
 	}
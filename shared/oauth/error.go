@@ -1,11 +1,12 @@
 package oauth
 
 const (
-	ErrorEmptyCredential     string = "Credential can't be empty"
-	ErrorClientNotFound      string = "Client does not exist"
-	ErrorInvalidPassword     string = "Invalid password credential"
-	ErrorInvalidClient       string = "Invalid client credentials"
-	ErrorInvalidToken        string = "Invalid Token"
-	ErrorTokenTypeMismatch   string = "Token type mismatch"
-	ErrorGenerateAccessToken string = "Error generating access token"
+	ErrorEmptyCredential      string = "Credential can't be empty"
+	ErrorClientNotFound       string = "Client does not exist"
+	ErrorInvalidPassword      string = "Invalid password credential"
+	ErrorInvalidClient        string = "Invalid client credentials"
+	ErrorInvalidToken         string = "Invalid Token"
+	ErrorTokenTypeMismatch    string = "Token type mismatch"
+	ErrorGenerateAccessToken  string = "Error generating access token"
+	ErrorUnsupportedGrantType string = "Unsupported grant type"
 )
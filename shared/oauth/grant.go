@@ -1,5 +1,12 @@
 package oauth
 
+import (
+	"context"
+	"errors"
+
+	"github.com/evermos/boilerplate-go/shared/audit"
+)
+
 type AuthorizationMethod interface {
 	Create(credential Credential) (OauthAccessToken, error)
 }
@@ -16,10 +23,48 @@ func NewGrant(tokenStore TokenStore, config Config) *Grant {
 	}
 }
 
-func (g *Grant) Create(credential Credential) (OauthAccessToken, error) {
+func (g *Grant) Create(ctx context.Context, credential Credential) (oauthAccessToken OauthAccessToken, err error) {
 	authMap := make(map[GrantType]AuthorizationMethod)
 	authMap[ClientCredentials] = &ClientCredentialsAuth{tokenStore: g.TokenStore, config: g.Config}
 	authMap[Password] = &PasswordAuth{tokenStore: g.TokenStore, config: g.Config}
 
-	return authMap[credential.GrantType].Create(credential)
+	defer func() {
+		g.record(ctx, credential, oauthAccessToken, err)
+	}()
+
+	auth, ok := authMap[credential.GrantType]
+	if !ok {
+		err = errors.New(ErrorUnsupportedGrantType)
+		return
+	}
+
+	return auth.Create(credential)
+}
+
+// record writes an audit event for a token request. Secrets and the issued
+// token itself are never recorded.
+func (g *Grant) record(ctx context.Context, credential Credential, oauthAccessToken OauthAccessToken, err error) {
+	if g.Config.Audit == nil {
+		return
+	}
+
+	event := audit.Event{
+		Type:      audit.EventTypeTokenIssued,
+		Actor:     credential.ClientID,
+		Subject:   oauthAccessToken.UserID.String,
+		IPAddress: credential.IPAddress,
+		UserAgent: credential.UserAgent,
+		Outcome:   audit.OutcomeSuccess,
+		Metadata: audit.Metadata{
+			"grantType": string(credential.GrantType),
+		},
+	}
+
+	if err != nil {
+		event.Subject = credential.Username
+		event.Outcome = audit.OutcomeFailure
+		event.Metadata["reason"] = err.Error()
+	}
+
+	g.Config.Audit.Record(ctx, event)
 }
@@ -0,0 +1,88 @@
+package oauth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/evermos/boilerplate-go/shared/audit"
+	"github.com/evermos/boilerplate-go/shared/oauth"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	events []audit.Event
+}
+
+func (r *recorder) Record(ctx context.Context, event audit.Event) {
+	r.events = append(r.events, event)
+}
+
+func TestGrantCreate(t *testing.T) {
+	credential := oauth.Credential{
+		GrantType:    oauth.ClientCredentials,
+		ClientID:     "client_web",
+		ClientSecret: "s3cret",
+		IPAddress:    "10.0.0.1",
+		UserAgent:    "curl/7.68.0",
+	}
+
+	t.Run("Records issued token", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM oauth_clients WHERE client_id = \\?").
+			WithArgs("client_web").
+			WillReturnRows(sqlmock.NewRows([]string{"client_id", "client_secret", "redirect_uri", "grant_types"}).
+				AddRow("client_web", "s3cret", "", "client_credentials"))
+		mock.ExpectPrepare("INSERT INTO oauth_access_tokens").
+			ExpectExec().
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		rec := &recorder{}
+		grant := oauth.NewGrant(oauth.NewTokenStore(sqlx.NewDb(db, "mysql")), oauth.Config{Audit: rec})
+		_, err = grant.Create(context.Background(), credential)
+
+		assert.NoError(t, err)
+		assert.Len(t, rec.events, 1)
+		event := rec.events[0]
+		assert.Equal(t, audit.EventTypeTokenIssued, event.Type)
+		assert.Equal(t, "client_web", event.Actor)
+		assert.Equal(t, "10.0.0.1", event.IPAddress)
+		assert.Equal(t, "curl/7.68.0", event.UserAgent)
+		assert.Equal(t, audit.OutcomeSuccess, event.Outcome)
+		assert.Equal(t, audit.Metadata{"grantType": "client_credentials"}, event.Metadata)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Records rejected secret without it", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT (.+) FROM oauth_clients WHERE client_id = \\?").
+			WithArgs("client_web").
+			WillReturnRows(sqlmock.NewRows([]string{"client_id", "client_secret", "redirect_uri", "grant_types"}).
+				AddRow("client_web", "other", "", "client_credentials"))
+
+		rec := &recorder{}
+		grant := oauth.NewGrant(oauth.NewTokenStore(sqlx.NewDb(db, "mysql")), oauth.Config{Audit: rec})
+		_, err = grant.Create(context.Background(), credential)
+
+		assert.Error(t, err)
+		assert.Len(t, rec.events, 1)
+		event := rec.events[0]
+		assert.Equal(t, audit.EventTypeTokenIssued, event.Type)
+		assert.Equal(t, "client_web", event.Actor)
+		assert.Equal(t, "10.0.0.1", event.IPAddress)
+		assert.Equal(t, "curl/7.68.0", event.UserAgent)
+		assert.Equal(t, audit.OutcomeFailure, event.Outcome)
+		assert.Equal(t, "client_credentials", event.Metadata["grantType"])
+		for _, value := range event.Metadata {
+			assert.NotContains(t, value, "s3cret")
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -27,6 +27,8 @@ type Credential struct {
 	ClientSecret string
 	Username     string
 	Password     string
+	IPAddress    string
+	UserAgent    string
 }
 
 type OauthAccessToken struct {
@@ -1,40 +1,123 @@
 package shared
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultInitialDelayRetry = 100 * time.Millisecond
+	defaultRetryJitter       = 0.5
+	retryMultiplier          = 2
 )
 
+var (
+	// ErrPubSubClosed is returned when publishing to a PubSub that is stopped.
+	ErrPubSubClosed = errors.New("pubsub is closed")
+	// ErrPubSubFull is returned by TryPublish when the buffer is full.
+	ErrPubSubFull = errors.New("pubsub buffer is full")
+	// ErrUnknownTopic is returned when publishing to a topic without a
+	// subscriber.
+	ErrUnknownTopic = errors.New("pubsub topic has no subscriber")
+)
+
+// PanicError is the error of a handler that panicked. Panics are not retried.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pubsub handler panicked: %v", e.Value)
+}
+
 type message struct {
 	topic   string
 	payload []byte
 }
 
 type Consumer struct {
-	message     chan message
-	messagePool chan chan message
-	runner      map[string]TopicRunner
+	message <-chan message
+	topics  *topicRegistry
+	stats   *pubsubStats
+	wg      *sync.WaitGroup
 }
 
-type Process func(message []byte) error
+// Process handles a message. ctx is cancelled when the PubSub is stopped and
+// its deadline to drain passes.
+type Process func(ctx context.Context, message []byte) error
+
+// DeadLetter receives a message its topic's handler failed to process, once
+// the failure is not retryable or its retries are exhausted. It may publish
+// the message to another topic, unless that risks blocking on a full buffer.
+type DeadLetter func(ctx context.Context, topic string, message []byte, err error)
 
 type consumerConfig struct {
 	MaxRetry           int
+	InitialDelayRetry  time.Duration
 	MaxDelayRetry      time.Duration
+	RetryJitter        float64
+	Retryable          func(err error) bool
+	DeadLetter         DeadLetter
 	AsynchronousThread bool
 }
 
+// SetMaxRetry sets how many times a message is processed before it is given
+// up on.
 func SetMaxRetry(maxRetry int) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
 		cc.MaxRetry = maxRetry
 	}
 }
 
+// SetInitialDelayRetry sets the delay before the first retry. The delay
+// doubles on every retry after it, up to the max delay.
+func SetInitialDelayRetry(delay time.Duration) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.InitialDelayRetry = delay
+	}
+}
+
+// SetMaxDelayRetry sets the longest delay between retries. Without it,
+// messages are retried immediately.
 func SetMaxDelayRetry(maxDelay time.Duration) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
 		cc.MaxDelayRetry = maxDelay
 	}
 }
 
+// SetRetryJitter sets how much each delay is randomized, as a fraction of it,
+// so messages failing together are not retried together.
+func SetRetryJitter(factor float64) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.RetryJitter = factor
+	}
+}
+
+// SetRetryable sets which errors are worth retrying. Other errors, and those
+// wrapped with backoff.Permanent, are given up on at once.
+func SetRetryable(retryable func(err error) bool) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.Retryable = retryable
+	}
+}
+
+// SetDeadLetter sets where messages that are given up on go. Without it,
+// they are logged and dropped.
+func SetDeadLetter(deadLetter DeadLetter) func(*consumerConfig) {
+	return func(cc *consumerConfig) {
+		cc.DeadLetter = deadLetter
+	}
+}
+
 // SetAsynchronousThread if enabled process will synchronously process by total flight
 func SetAsynchronousThread(sync bool) func(*consumerConfig) {
 	return func(cc *consumerConfig) {
@@ -45,50 +128,77 @@ func SetAsynchronousThread(sync bool) func(*consumerConfig) {
 func defaultConsumerConfig() consumerConfig {
 	return consumerConfig{
 		MaxRetry:           0,
+		InitialDelayRetry:  defaultInitialDelayRetry,
 		MaxDelayRetry:      0 * time.Second,
+		RetryJitter:        defaultRetryJitter,
+		Retryable:          func(err error) bool { return true },
 		AsynchronousThread: false,
 	}
 }
 
-func consumer(messagePol chan chan message, subscribers map[string]TopicRunner) Consumer {
+func consumer(messages <-chan message, topics *topicRegistry, wg *sync.WaitGroup) Consumer {
 	return Consumer{
-		message:     make(chan message),
-		messagePool: messagePol,
-		runner:      subscribers,
+		message: messages,
+		topics:  topics,
+		wg:      wg,
 	}
 }
 
-func (c Consumer) consume() {
+// consume processes messages until the PubSub is stopped and its messages are
+// drained. Once ctx is cancelled, the messages left are dropped.
+func (c Consumer) consume(ctx context.Context) {
+	c.wg.Add(1)
 	go func() {
-		for {
-			// starts out empty
-			// send the response to dispatcher
-			c.messagePool <- c.message
-
-			// read the response
-			msg := <-c.message
-
-			runner := c.runner[msg.topic]
-			if runner.consumerConfig.AsynchronousThread {
-				go runner.backoff(func() error {
-					return runner.Process(msg.payload)
-				})
+		defer c.wg.Done()
+
+		for msg := range c.message {
+			if ctx.Err() != nil {
 				continue
 			}
 
-			// if enabled process concurrent will handle by max flight
-			runner.backoff(func() error {
-				return runner.Process(msg.payload)
-			})
+			// subscribers that left since the message was published miss it
+			runners := c.topics.match(msg.topic)
+			if len(runners) == 0 {
+				log.Warn().Str("topic", msg.topic).Msg("No subscriber for message, dropping it.")
+				continue
+			}
+
+			for _, runner := range runners {
+				if runner.consumerConfig.AsynchronousThread {
+					c.wg.Add(1)
+					go func(runner TopicRunner, msg message) {
+						defer c.wg.Done()
+						runner.run(ctx, msg)
+					}(runner, msg)
+					continue
+				}
+
+				// if enabled process concurrent will handle by max flight
+				runner.run(ctx, msg)
+			}
 		}
 	}()
 }
 
+// PubSub is an in-process publisher and subscriber. Messages are processed by
+// a fixed number of workers, until Stop drains them. Every subscriber of a
+// topic receives each of its messages, in the order they subscribed; a
+// subscriber that is not asynchronous holds up the others while it retries.
 type PubSub struct {
-	message     chan message
-	messagePool chan chan message
-	max         int
-	topics      map[string]TopicRunner
+	message chan message
+	max     int
+	topics  *topicRegistry
+	stats   *pubsubStats
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu guards closed, so message is never sent to once it is closed.
+	mu       sync.RWMutex
+	closed   bool
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
 type pubsubConfig struct {
@@ -110,82 +220,317 @@ func SetMessageBuffer(maxBuffer int) func(*pubsubConfig) {
 type TopicRunner struct {
 	Process        Process
 	consumerConfig consumerConfig
+	stats          *pubsubStats
+}
+
+type subscription struct {
+	pattern string
+	runner  TopicRunner
+}
+
+// topicRegistry holds the subscriptions of a PubSub in the order they were
+// made. It is guarded, as subscribers may come and go while workers dispatch
+// messages.
+type topicRegistry struct {
+	mu            sync.RWMutex
+	subscriptions []*subscription
+}
+
+func (r *topicRegistry) add(s *subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subscriptions = append(r.subscriptions, s)
 }
 
-func (r TopicRunner) backoff(exec func() error) error {
-	var err error
+func (r *topicRegistry) remove(s *subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	if r.consumerConfig.MaxRetry == 0 {
-		return exec()
+	for i, existing := range r.subscriptions {
+		if existing == s {
+			r.subscriptions = append(r.subscriptions[:i:i], r.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// match returns the runners of every subscription to a topic.
+func (r *topicRegistry) match(topic string) []TopicRunner {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var runners []TopicRunner
+	for _, s := range r.subscriptions {
+		if matchTopic(s.pattern, topic) {
+			runners = append(runners, s.runner)
+		}
 	}
+	return runners
+}
+
+func (r *topicRegistry) has(topic string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-	counter := 0
-	for counter < r.consumerConfig.MaxRetry {
-		err = exec()
-		if err == nil {
-			return err
+	for _, s := range r.subscriptions {
+		if matchTopic(s.pattern, topic) {
+			return true
 		}
+	}
+	return false
+}
+
+// matchTopic reports whether a topic matches a subscription pattern. Topics
+// are made of dot-separated segments, and a * segment in a pattern matches any
+// single segment, so foo.* matches foo.bar but neither foo nor foo.bar.baz.
+func matchTopic(pattern string, topic string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == topic
+	}
 
-		counter++
-		time.Sleep(r.consumerConfig.MaxDelayRetry)
+	patternSegments := strings.Split(pattern, ".")
+	topicSegments := strings.Split(topic, ".")
+	if len(patternSegments) != len(topicSegments) {
+		return false
 	}
 
-	return err
+	for i, segment := range patternSegments {
+		if segment != "*" && segment != topicSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// run processes a message, retrying it while it fails, and dead-letters it
+// once it is given up on.
+func (r TopicRunner) run(ctx context.Context, msg message) {
+	err := r.backoff(ctx, func() error {
+		return r.process(ctx, msg)
+	}, func(error, time.Duration) {
+		r.stats.retried(msg.topic)
+	})
+	if err == nil {
+		return
+	}
+
+	r.stats.deadLettered(msg.topic)
+
+	if r.consumerConfig.DeadLetter != nil {
+		r.consumerConfig.DeadLetter(ctx, msg.topic, msg.payload, err)
+		return
+	}
+
+	log.Error().Err(err).Str("topic", msg.topic).Msg("Failed processing message, dropping it.")
+}
+
+// process runs the handler, turning a panic into a PanicError so the worker
+// survives it.
+func (r TopicRunner) process(ctx context.Context, msg message) (err error) {
+	start := time.Now()
+	r.stats.begin()
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := &PanicError{Value: value, Stack: debug.Stack()}
+			log.Error().Str("stack", string(panicErr.Stack)).Msg(panicErr.Error())
+			err = backoff.Permanent(panicErr)
+		}
+
+		r.stats.end()
+		r.stats.handled(msg.topic, time.Since(start), err)
+	}()
+
+	return r.Process(ctx, msg.payload)
+}
+
+// backoff retries exec with an exponentially growing, jittered delay until it
+// succeeds, fails with an error that is not retryable, runs out of retries or
+// ctx is cancelled. notify is called before every retry.
+func (r TopicRunner) backoff(ctx context.Context, exec func() error, notify backoff.Notify) error {
+	retries := r.consumerConfig.MaxRetry - 1
+	if retries < 0 {
+		retries = 0
+	}
+
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = r.consumerConfig.InitialDelayRetry
+	if policy.InitialInterval > r.consumerConfig.MaxDelayRetry {
+		policy.InitialInterval = r.consumerConfig.MaxDelayRetry
+	}
+	policy.MaxInterval = r.consumerConfig.MaxDelayRetry
+	policy.RandomizationFactor = r.consumerConfig.RetryJitter
+	policy.Multiplier = retryMultiplier
+	policy.MaxElapsedTime = 0
+
+	return backoff.RetryNotify(func() error {
+		err := exec()
+
+		var permanent *backoff.PermanentError
+		if err != nil && !errors.As(err, &permanent) && !r.consumerConfig.Retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(policy, uint64(retries)), ctx), notify)
 }
 
 // max is a total process could be handle
-func New(maxFlight int, opts ...func(*pubsubConfig)) PubSub {
+func New(maxFlight int, opts ...func(*pubsubConfig)) *PubSub {
 	config := defaultPubsubConfig()
 	for _, opt := range opts {
 		opt(&config)
 	}
 
-	return PubSub{
-		message:     make(chan message, config.MessageBuffer),
-		messagePool: make(chan chan message),
-		max:         maxFlight,
-		topics:      make(map[string]TopicRunner),
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PubSub{
+		message: make(chan message, config.MessageBuffer),
+		max:     maxFlight,
+		topics:  &topicRegistry{},
+		stats:   newPubSubStats(),
+		ctx:     ctx,
+		cancel:  cancel,
+		quit:    make(chan struct{}),
 	}
 }
 
-func (p PubSub) Publish(topic string, payload []byte) {
-	p.message <- message{
-		topic:   topic,
-		payload: payload,
+// Publish queues a message for a topic, blocking while the buffer is full.
+func (p *PubSub) Publish(topic string, payload []byte) error {
+	return p.PublishContext(context.Background(), topic, payload)
+}
+
+// PublishContext queues a message for a topic, blocking while the buffer is
+// full until ctx ends. It fails with ErrUnknownTopic if nobody subscribes to
+// the topic, and with ErrPubSubClosed once the PubSub is stopped.
+func (p *PubSub) PublishContext(ctx context.Context, topic string, payload []byte) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if err := p.accepts(topic); err != nil {
+		return err
+	}
+
+	select {
+	case p.message <- message{topic: topic, payload: payload}:
+		p.stats.published(topic)
+		return nil
+	case <-p.quit:
+		return ErrPubSubClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryPublish queues a message for a topic without blocking, failing with
+// ErrPubSubFull if the buffer is full.
+func (p *PubSub) TryPublish(topic string, payload []byte) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if err := p.accepts(topic); err != nil {
+		return err
+	}
+
+	select {
+	case p.message <- message{topic: topic, payload: payload}:
+		p.stats.published(topic)
+		return nil
+	default:
+		return ErrPubSubFull
+	}
+}
+
+func (p *PubSub) accepts(topic string) error {
+	if p.closed {
+		return ErrPubSubClosed
 	}
+
+	if !p.topics.has(topic) {
+		return fmt.Errorf("%w: %s", ErrUnknownTopic, topic)
+	}
+
+	return nil
 }
 
-func (p PubSub) SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) {
+// SubscriberRegistry subscribes a handler to a topic, or to every topic
+// matching a pattern such as foo.*, with its own retry config. Messages stop
+// reaching it once unsubscribe is called, though those it is processing are
+// finished.
+func (p *PubSub) SubscriberRegistry(topicListener string, pr Process, opts ...func(*consumerConfig)) (unsubscribe func()) {
 	cfg := defaultConsumerConfig()
 
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
-	p.topics[topicListener] = TopicRunner{
-		Process:        pr,
-		consumerConfig: cfg,
+	s := &subscription{
+		pattern: topicListener,
+		runner: TopicRunner{
+			Process:        pr,
+			consumerConfig: cfg,
+			stats:          p.stats,
+		},
+	}
+	p.topics.add(s)
+
+	return func() {
+		p.topics.remove(s)
 	}
 }
 
-func (p PubSub) Start() {
+func (p *PubSub) Start() {
 	for i := 0; i < p.max; i++ {
-		consumer := consumer(p.messagePool, p.topics)
-		consumer.consume()
+		consumer := consumer(p.message, p.topics, &p.wg)
+		consumer.consume(p.ctx)
 	}
-
-	go p.dispatch()
 }
 
-func (p PubSub) dispatch() {
-	for {
-		// waiting from p.Message from instantiate
-		msg := <-p.message
+// Stop stops accepting messages and waits for the workers to process the ones
+// already published. If ctx ends first, the context given to handlers is
+// cancelled, the messages left are dropped, and ctx's error is returned
+// without waiting further.
+func (p *PubSub) Stop(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		// unblock publishers waiting on a full buffer, so closed can be set
+		close(p.quit)
+
+		p.mu.Lock()
+		p.closed = true
+		close(p.message)
+		p.mu.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
 
-		// read the response from consume
-		response := <-p.messagePool
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
 
-		// send value from function Publish to response / consumer
-		response <- msg
+// Stats returns a snapshot of the queue, the workers and the messages of
+// every topic.
+func (p *PubSub) Stats() Stats {
+	busy, topics := p.stats.snapshot()
+
+	return Stats{
+		QueueDepth:    len(p.message),
+		QueueCapacity: cap(p.message),
+		Workers:       p.max,
+		BusyWorkers:   busy,
+		Topics:        topics,
 	}
 }
+
+// Close stops the PubSub, waiting for every published message to be
+// processed.
+func (p *PubSub) Close() error {
+	return p.Stop(context.Background())
+}
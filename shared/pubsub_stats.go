@@ -0,0 +1,140 @@
+package shared
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the handler latency histogram.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Stats is a snapshot of the state of a PubSub.
+type Stats struct {
+	// QueueDepth is how many published messages wait for a worker.
+	QueueDepth    int `json:"queueDepth"`
+	QueueCapacity int `json:"queueCapacity"`
+	Workers       int `json:"workers"`
+	// BusyWorkers is how many handlers are running, including asynchronous
+	// ones.
+	BusyWorkers int64                 `json:"busyWorkers"`
+	Topics      map[string]TopicStats `json:"topics"`
+}
+
+// TopicStats counts what happened to the messages of a topic. Processed,
+// Failed and Latency count every handler attempt, so a message with two
+// subscribers is processed twice. DeadLettered counts the messages given up
+// on, whether they went to a dead letter or were dropped.
+type TopicStats struct {
+	Published    int64            `json:"published"`
+	Processed    int64            `json:"processed"`
+	Failed       int64            `json:"failed"`
+	Retried      int64            `json:"retried"`
+	DeadLettered int64            `json:"deadLettered"`
+	Latency      LatencyHistogram `json:"latency"`
+}
+
+// LatencyHistogram is the distribution of handler latencies in seconds.
+// Buckets are cumulative, like Prometheus histograms: each counts the
+// attempts that took at most its upper bound.
+type LatencyHistogram struct {
+	Count      int64             `json:"count"`
+	SumSeconds float64           `json:"sumSeconds"`
+	Buckets    []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket is a bucket of a LatencyHistogram.
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+// pubsubStats collects the counters behind Stats.
+type pubsubStats struct {
+	busy   int64
+	mu     sync.Mutex
+	topics map[string]*TopicStats
+}
+
+func newPubSubStats() *pubsubStats {
+	return &pubsubStats{topics: make(map[string]*TopicStats)}
+}
+
+// update changes the stats of a topic, creating them if needed.
+func (s *pubsubStats) update(topic string, change func(stats *TopicStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.topics[topic]
+	if !ok {
+		stats = &TopicStats{Latency: LatencyHistogram{Buckets: make([]HistogramBucket, len(latencyBuckets))}}
+		for i, bound := range latencyBuckets {
+			stats.Latency.Buckets[i].UpperBound = bound.Seconds()
+		}
+		s.topics[topic] = stats
+	}
+	change(stats)
+}
+
+func (s *pubsubStats) published(topic string) {
+	s.update(topic, func(stats *TopicStats) { stats.Published++ })
+}
+
+func (s *pubsubStats) retried(topic string) {
+	s.update(topic, func(stats *TopicStats) { stats.Retried++ })
+}
+
+func (s *pubsubStats) deadLettered(topic string) {
+	s.update(topic, func(stats *TopicStats) { stats.DeadLettered++ })
+}
+
+// handled records a handler attempt and how long it took.
+func (s *pubsubStats) handled(topic string, latency time.Duration, err error) {
+	s.update(topic, func(stats *TopicStats) {
+		if err != nil {
+			stats.Failed++
+		} else {
+			stats.Processed++
+		}
+
+		stats.Latency.Count++
+		stats.Latency.SumSeconds += latency.Seconds()
+		for i, bound := range latencyBuckets {
+			if latency <= bound {
+				stats.Latency.Buckets[i].Count++
+			}
+		}
+	})
+}
+
+func (s *pubsubStats) begin() {
+	atomic.AddInt64(&s.busy, 1)
+}
+
+func (s *pubsubStats) end() {
+	atomic.AddInt64(&s.busy, -1)
+}
+
+// snapshot copies the stats of every topic.
+func (s *pubsubStats) snapshot() (busy int64, topics map[string]TopicStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	topics = make(map[string]TopicStats, len(s.topics))
+	for topic, stats := range s.topics {
+		copied := *stats
+		copied.Latency.Buckets = append([]HistogramBucket(nil), stats.Latency.Buckets...)
+		topics[topic] = copied
+	}
+
+	return atomic.LoadInt64(&s.busy), topics
+}
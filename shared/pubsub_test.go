@@ -1,10 +1,13 @@
 package shared_test
 
 import (
+	"context"
 	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/evermos/boilerplate-go/shared"
 	"github.com/stretchr/testify/assert"
 )
@@ -14,14 +17,14 @@ func TestPubSub(t *testing.T) {
 		expected := "Testing"
 		var actual string
 		pubsub := shared.New(1, shared.SetMessageBuffer(10))
-		pubsub.SubscriberRegistry("test", func(message []byte) error {
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
 			actual = string(message)
 			return nil
 		})
 		pubsub.Start()
-		pubsub.Publish("test", []byte("Testing"))
+		assert.NoError(t, pubsub.Publish("test", []byte("Testing")))
 
-		time.Sleep(1 * time.Second)
+		assert.NoError(t, pubsub.Close())
 		assert.Equal(t, expected, actual)
 	})
 
@@ -29,14 +32,14 @@ func TestPubSub(t *testing.T) {
 		expected := "Testing"
 		var actual string
 		pubsub := shared.New(1, shared.SetMessageBuffer(10))
-		pubsub.SubscriberRegistry("test", func(message []byte) error {
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
 			actual = string(message)
 			return nil
 		})
 		pubsub.Start()
-		pubsub.Publish("test", []byte("b"))
+		assert.NoError(t, pubsub.Publish("test", []byte("b")))
 
-		time.Sleep(1 * time.Second)
+		assert.NoError(t, pubsub.Close())
 		assert.NotEqual(t, expected, actual)
 	})
 
@@ -46,38 +49,311 @@ func TestPubSub(t *testing.T) {
 		retryCountTest := 0
 		retryCountTest2 := 0
 		pubsub := shared.New(1, shared.SetMessageBuffer(10))
-		pubsub.SubscriberRegistry("test", func(message []byte) error {
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
 			retryCountTest++
 			return errors.New("error test retry")
 		}, shared.SetMaxRetry(5))
-		pubsub.SubscriberRegistry("test-2", func(message []byte) error {
+		pubsub.SubscriberRegistry("test-2", func(ctx context.Context, message []byte) error {
 			retryCountTest2++
 			return errors.New("error test retry")
 		}, shared.SetMaxRetry(2))
 
 		pubsub.Start()
-		pubsub.Publish("test", []byte("b"))
-		pubsub.Publish("test-2", []byte("b"))
+		assert.NoError(t, pubsub.Publish("test", []byte("b")))
+		assert.NoError(t, pubsub.Publish("test-2", []byte("b")))
 
-		time.Sleep(1 * time.Second)
+		assert.NoError(t, pubsub.Close())
 		assert.Equal(t, expectedTopicTest, retryCountTest)
 		assert.Equal(t, expectedTopicTest2, retryCountTest2)
 	})
 
 	t.Run("Test Race", func(t *testing.T) {
-		counter := 0
+		var counter int64
 		pubsub := shared.New(10)
-		pubsub.SubscriberRegistry("test", func(message []byte) error {
-			counter++
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			atomic.AddInt64(&counter, 1)
 			return nil
 		}, shared.SetAsynchronousThread(true))
 		pubsub.Start()
 
 		for i := 0; i < 1000; i++ {
-			pubsub.Publish("test", []byte("test"))
+			assert.NoError(t, pubsub.Publish("test", []byte("test")))
 		}
 
-		time.Sleep(3 * time.Second)
-		assert.Equal(t, 1000, counter)
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, int64(1000), atomic.LoadInt64(&counter))
+	})
+
+	t.Run("Rejects publishing once stopped", func(t *testing.T) {
+		pubsub := shared.New(1)
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, shared.ErrPubSubClosed, pubsub.Publish("test", []byte("test")))
+	})
+
+	t.Run("Cancels handlers once the deadline to drain passes", func(t *testing.T) {
+		var processed int64
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			atomic.AddInt64(&processed, 1)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		pubsub.Start()
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		assert.Equal(t, context.DeadlineExceeded, pubsub.Stop(ctx))
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, int64(1), atomic.LoadInt64(&processed))
+	})
+
+	t.Run("Dead-letters messages once retries are exhausted", func(t *testing.T) {
+		attempts := 0
+		var deadLettered []string
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			attempts++
+			return errors.New("error test retry")
+		},
+			shared.SetMaxRetry(4),
+			shared.SetInitialDelayRetry(time.Millisecond),
+			shared.SetMaxDelayRetry(5*time.Millisecond),
+			shared.SetDeadLetter(func(ctx context.Context, topic string, message []byte, err error) {
+				deadLettered = append(deadLettered, topic+":"+string(message)+":"+err.Error())
+			}))
+		pubsub.Start()
+		assert.NoError(t, pubsub.Publish("test", []byte("b")))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, 4, attempts)
+		assert.Equal(t, []string{"test:b:error test retry"}, deadLettered)
+	})
+
+	t.Run("Does not retry failures that are not retryable", func(t *testing.T) {
+		malformed := errors.New("malformed")
+		attempts := 0
+		var deadLettered []error
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			attempts++
+			if string(message) == "permanent" {
+				return backoff.Permanent(errors.New("permanent"))
+			}
+			return malformed
+		},
+			shared.SetMaxRetry(5),
+			shared.SetRetryable(func(err error) bool { return err != malformed }),
+			shared.SetDeadLetter(func(ctx context.Context, topic string, message []byte, err error) {
+				deadLettered = append(deadLettered, err)
+			}))
+		pubsub.Start()
+		assert.NoError(t, pubsub.Publish("test", []byte("malformed")))
+		assert.NoError(t, pubsub.Publish("test", []byte("permanent")))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, 2, attempts)
+		assert.Len(t, deadLettered, 2)
+		assert.Equal(t, malformed, deadLettered[0])
+		assert.EqualError(t, deadLettered[1], "permanent")
+	})
+
+	t.Run("Stops retrying once stopped", func(t *testing.T) {
+		var attempts int64
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			atomic.AddInt64(&attempts, 1)
+			return errors.New("error test retry")
+		}, shared.SetMaxRetry(100), shared.SetInitialDelayRetry(time.Hour), shared.SetMaxDelayRetry(time.Hour))
+		pubsub.Start()
+		assert.NoError(t, pubsub.Publish("test", []byte("b")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		assert.Equal(t, context.DeadlineExceeded, pubsub.Stop(ctx))
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, int64(1), atomic.LoadInt64(&attempts))
+	})
+
+	t.Run("Rejects publishing to unknown topics", func(t *testing.T) {
+		pubsub := shared.New(1)
+		pubsub.Start()
+		defer pubsub.Close()
+
+		assert.True(t, errors.Is(pubsub.Publish("unknown", []byte("test")), shared.ErrUnknownTopic))
+		assert.True(t, errors.Is(pubsub.TryPublish("unknown", []byte("test")), shared.ErrUnknownTopic))
+	})
+
+	t.Run("Publishes without blocking on a full buffer", func(t *testing.T) {
+		pubsub := shared.New(1, shared.SetMessageBuffer(1))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			return nil
+		})
+
+		assert.NoError(t, pubsub.TryPublish("test", []byte("test")))
+		assert.Equal(t, shared.ErrPubSubFull, pubsub.TryPublish("test", []byte("test")))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, pubsub.PublishContext(ctx, "test", []byte("test")))
+
+		pubsub.Start()
+		assert.NoError(t, pubsub.Close())
+	})
+
+	t.Run("Recovers from handler panics", func(t *testing.T) {
+		var processed []string
+		var deadLettered []error
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			if string(message) == "panic" {
+				panic("boom")
+			}
+			processed = append(processed, string(message))
+			return nil
+		},
+			shared.SetMaxRetry(3),
+			shared.SetDeadLetter(func(ctx context.Context, topic string, message []byte, err error) {
+				deadLettered = append(deadLettered, err)
+			}))
+		pubsub.Start()
+		assert.NoError(t, pubsub.Publish("test", []byte("panic")))
+		assert.NoError(t, pubsub.Publish("test", []byte("after")))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, []string{"after"}, processed)
+		if assert.Len(t, deadLettered, 1) {
+			panicErr, ok := deadLettered[0].(*shared.PanicError)
+			assert.True(t, ok)
+			assert.Equal(t, "boom", panicErr.Value)
+		}
+	})
+
+	t.Run("Fans out to every subscriber with its own retries", func(t *testing.T) {
+		firstAttempts, secondAttempts := 0, 0
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			firstAttempts++
+			return errors.New("error test retry")
+		}, shared.SetMaxRetry(3))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			secondAttempts++
+			return nil
+		}, shared.SetMaxRetry(5))
+		pubsub.Start()
+		assert.NoError(t, pubsub.Publish("test", []byte("b")))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, 3, firstAttempts)
+		assert.Equal(t, 1, secondAttempts)
+	})
+
+	t.Run("Matches wildcard subscriptions", func(t *testing.T) {
+		var received []string
+		pubsub := shared.New(1, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("foo.*", func(ctx context.Context, message []byte) error {
+			received = append(received, string(message))
+			return nil
+		})
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("foo.bar", []byte("foo.bar")))
+		assert.NoError(t, pubsub.Publish("foo.baz", []byte("foo.baz")))
+		assert.True(t, errors.Is(pubsub.Publish("foo", []byte("foo")), shared.ErrUnknownTopic))
+		assert.True(t, errors.Is(pubsub.Publish("foo.bar.baz", []byte("foo.bar.baz")), shared.ErrUnknownTopic))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, []string{"foo.bar", "foo.baz"}, received)
+	})
+
+	t.Run("Stops delivering to unsubscribed handlers", func(t *testing.T) {
+		var first, second int64
+		pubsub := shared.New(1)
+		unsubscribe := pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			atomic.AddInt64(&first, 1)
+			return nil
+		})
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			atomic.AddInt64(&second, 1)
+			return nil
+		})
+		pubsub.Start()
+
+		assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		unsubscribe()
+		unsubscribe()
+		assert.NoError(t, pubsub.Publish("test", []byte("test")))
+
+		assert.NoError(t, pubsub.Close())
+		assert.Equal(t, int64(2), atomic.LoadInt64(&second))
+		assert.LessOrEqual(t, atomic.LoadInt64(&first), int64(1))
+	})
+
+	t.Run("Subscribes while messages are dispatched", func(t *testing.T) {
+		pubsub := shared.New(4, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			return nil
+		})
+		pubsub.Start()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 100; i++ {
+				unsubscribe := pubsub.SubscriberRegistry("test.*", func(ctx context.Context, message []byte) error {
+					return nil
+				})
+				unsubscribe()
+			}
+		}()
+
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, pubsub.Publish("test", []byte("test")))
+		}
+
+		<-done
+		assert.NoError(t, pubsub.Close())
+	})
+
+	t.Run("Reports stats", func(t *testing.T) {
+		pubsub := shared.New(2, shared.SetMessageBuffer(10))
+		pubsub.SubscriberRegistry("test", func(ctx context.Context, message []byte) error {
+			if string(message) == "fail" {
+				return errors.New("error test retry")
+			}
+			return nil
+		}, shared.SetMaxRetry(3))
+
+		assert.NoError(t, pubsub.Publish("test", []byte("ok")))
+		assert.NoError(t, pubsub.Publish("test", []byte("fail")))
+
+		stats := pubsub.Stats()
+		assert.Equal(t, 2, stats.QueueDepth)
+		assert.Equal(t, 10, stats.QueueCapacity)
+		assert.Equal(t, 2, stats.Workers)
+
+		pubsub.Start()
+		assert.NoError(t, pubsub.Close())
+
+		stats = pubsub.Stats()
+		assert.Equal(t, 0, stats.QueueDepth)
+		assert.Equal(t, int64(0), stats.BusyWorkers)
+
+		topic := stats.Topics["test"]
+		assert.Equal(t, int64(2), topic.Published)
+		assert.Equal(t, int64(1), topic.Processed)
+		assert.Equal(t, int64(3), topic.Failed)
+		assert.Equal(t, int64(2), topic.Retried)
+		assert.Equal(t, int64(1), topic.DeadLettered)
+		assert.Equal(t, int64(4), topic.Latency.Count)
+		last := topic.Latency.Buckets[len(topic.Latency.Buckets)-1]
+		assert.Equal(t, int64(4), last.Count)
 	})
 }
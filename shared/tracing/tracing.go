@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterOTLP exports spans over OTLP/HTTP, e.g. to a local collector.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stdout, e.g. for tests.
+	ExporterStdout = "stdout"
+
+	instrumentationName = "github.com/evermos/boilerplate-go"
+	defaultServiceName  = "boilerplate-go"
+)
+
+// Init sets up the global tracer provider from Tracing, and the W3C trace
+// context and baggage propagator. While tracing is disabled, spans are not
+// recorded, but trace context is still propagated. The returned function
+// flushes pending spans and must be called on shutdown.
+func Init(config *configs.Config) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !config.Tracing.Enabled {
+		return func(ctx context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(config, exporter)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider exporting to exporter, sampling
+// Tracing.SampleRatio of the traces started by this service. Traces started
+// upstream keep their sampling decision.
+func NewProvider(config *configs.Config, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	serviceName := config.App.Name
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	ratio := config.Tracing.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(config.App.Revision),
+		)),
+	)
+}
+
+func newExporter(config *configs.Config) (sdktrace.SpanExporter, error) {
+	if config.Tracing.Exporter == ExporterStdout {
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	}
+
+	options := []otlptracehttp.Option{}
+	if config.Tracing.OTLP.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpoint(config.Tracing.OTLP.Endpoint))
+	}
+	if config.Tracing.OTLP.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	return otlptracehttp.New(context.Background(), options...)
+}
+
+// Tracer returns the tracer of this service.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, options...)
+}
+
+// End ends a span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartQuery starts a span around MySQL queries, e.g. those of a repository
+// method. The statement is recorded unless it is empty.
+func StartQuery(ctx context.Context, name string, statement string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{semconv.DBSystemMySQL}
+	if statement != "" {
+		attributes = append(attributes, semconv.DBStatementKey.String(statement))
+	}
+
+	return Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...))
+}
+
+// Inject returns a copy of attributes carrying the trace context of ctx, to
+// be sent along with a message. attributes is returned as is when ctx has no
+// span.
+func Inject(ctx context.Context, attributes map[string]string) map[string]string {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return attributes
+	}
+
+	carrier := mapCarrier{}
+	for key, value := range attributes {
+		carrier[key] = value
+	}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier
+}
+
+// Extract returns a copy of ctx with the trace context carried by the
+// attributes of a message, if any.
+func Extract(ctx context.Context, attributes map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, mapCarrier(attributes))
+}
+
+// mapCarrier carries trace context in the attributes of a message.
+type mapCarrier map[string]string
+
+func (c mapCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c mapCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c mapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// MessagingAttributes describes a message sent to or received from a
+// destination, e.g. an SNS topic or SQS queue.
+func MessagingAttributes(system string, destination string) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		semconv.MessagingSystemKey.String(system),
+		semconv.MessagingDestinationKey.String(destination),
+	)
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"expvar"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,6 +14,8 @@ import (
 	"github.com/evermos/boilerplate-go/docs"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/shared/metrics"
+	appMiddleware "github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/response"
 	"github.com/evermos/boilerplate-go/transport/http/router"
 	"github.com/go-chi/chi"
@@ -37,6 +40,10 @@ const (
 	ServerStateInCleanupPeriod
 )
 
+// HealthChecker reports the state of a component on the health endpoint, and
+// whether it is healthy.
+type HealthChecker func() (state interface{}, healthy bool)
+
 // HTTP is the HTTP server.
 type HTTP struct {
 	Config *configs.Config
@@ -44,6 +51,9 @@ type HTTP struct {
 	Router router.Router
 	State  ServerState
 	mux    *chi.Mux
+
+	cleanups     []func()
+	healthChecks map[string]HealthChecker
 }
 
 // ProvideHTTP is the provider for HTTP.
@@ -55,6 +65,15 @@ func ProvideHTTP(db *infras.MySQLConn, config *configs.Config, router router.Rou
 	}
 }
 
+// ProvideHealthHTTP is the provider for an HTTP that only serves the health
+// check, for processes that do not serve the API, e.g. workers.
+func ProvideHealthHTTP(db *infras.MySQLConn, config *configs.Config) *HTTP {
+	return &HTTP{
+		DB:     db,
+		Config: config,
+	}
+}
+
 // SetupAndServe sets up the server and gets it up and running.
 func (h *HTTP) SetupAndServe() {
 	h.mux = chi.NewRouter()
@@ -65,6 +84,7 @@ func (h *HTTP) SetupAndServe() {
 	h.State = ServerStateReady
 
 	h.logServerInfo()
+	h.serveInternal()
 
 	log.Info().Str("port", h.Config.Server.Port).Msg("Starting up HTTP server.")
 
@@ -74,6 +94,41 @@ func (h *HTTP) SetupAndServe() {
 	}
 }
 
+// OnCleanup registers a function that will be run once the server enters its
+// cleanup period, e.g. to stop background workers.
+func (h *HTTP) OnCleanup(cleanup func()) {
+	h.cleanups = append(h.cleanups, cleanup)
+}
+
+// SetupAndServeHealth sets up a server that only serves the health check,
+// for processes that do not serve the API, e.g. workers. The metrics and debug
+// endpoints are served on the internal port, as with SetupAndServe.
+func (h *HTTP) SetupAndServeHealth() {
+	h.mux = chi.NewRouter()
+	h.setupMiddleware()
+	h.mux.Get("/health", h.HealthCheck)
+	h.setupGracefulShutdown()
+	h.State = ServerStateReady
+
+	h.serveInternal()
+
+	log.Info().Str("port", h.Config.Server.Port).Msg("Starting up HTTP health server.")
+
+	err := http.ListenAndServe(":"+h.Config.Server.Port, h.mux)
+	if err != nil {
+		logger.ErrorWithStack(err)
+	}
+}
+
+// OnHealthCheck registers a component whose state is reported on the health
+// endpoint. The server is unhealthy when any component is.
+func (h *HTTP) OnHealthCheck(name string, check HealthChecker) {
+	if h.healthChecks == nil {
+		h.healthChecks = make(map[string]HealthChecker)
+	}
+	h.healthChecks[name] = check
+}
+
 func (h *HTTP) setupSwaggerDocs() {
 	if h.Config.Server.Env == "development" {
 		docs.SwaggerInfo.Title = h.Config.App.Name
@@ -89,6 +144,30 @@ func (h *HTTP) setupRoutes() {
 	h.Router.SetupRoutes(h.mux)
 }
 
+// serveInternal serves the metrics and debug endpoints in the background on
+// Server.InternalPort, which must not be exposed publicly. They are not served
+// at all without one.
+func (h *HTTP) serveInternal() {
+	port := h.Config.Server.InternalPort
+	if port == "" {
+		log.Warn().Msg("No internal port set, metrics and debug endpoints are disabled.")
+		return
+	}
+
+	mux := chi.NewRouter()
+	mux.Use(middleware.Recoverer)
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics.Handler())
+
+	log.Info().Str("port", port).Msg("Starting up internal HTTP server.")
+
+	go func() {
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			logger.ErrorWithStack(err)
+		}
+	}()
+}
+
 func (h *HTTP) setupGracefulShutdown() {
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
@@ -108,14 +187,20 @@ func (h *HTTP) respondToSigterm(done chan os.Signal) {
 
 	log.Info().Int64("seconds", shutdownConfig.CleanupPeriodSeconds).Msg("Entering cleanup period.")
 	h.State = ServerStateInCleanupPeriod
+	for _, cleanup := range h.cleanups {
+		cleanup()
+	}
 	time.Sleep(time.Duration(shutdownConfig.CleanupPeriodSeconds) * time.Second)
 
 	log.Info().Msg("Cleaning up completed. Shutting down now.")
 }
 
 func (h *HTTP) setupMiddleware() {
-	h.mux.Use(middleware.Logger)
+	h.mux.Use(appMiddleware.RequestID)
+	h.mux.Use(appMiddleware.Logger)
 	h.mux.Use(middleware.Recoverer)
+	h.mux.Use(appMiddleware.Tracing)
+	h.mux.Use(appMiddleware.Metrics)
 	h.mux.Use(h.serverStateMiddleware)
 	h.setupCORS()
 }
@@ -166,6 +251,7 @@ func (h *HTTP) setupCORS() {
 			AllowedHeaders:   corsConfig.AllowedHeaders,
 			AllowedMethods:   corsConfig.AllowedMethods,
 			AllowedOrigins:   corsConfig.AllowedOrigins,
+			ExposedHeaders:   []string{appMiddleware.HeaderRequestID},
 			MaxAge:           corsConfig.MaxAgeSeconds,
 		}))
 	}
@@ -187,5 +273,14 @@ func (h *HTTP) HealthCheck(w http.ResponseWriter, r *http.Request) {
 		response.WithUnhealthy(w)
 		return
 	}
-	response.WithMessage(w, http.StatusOK, "OK")
+
+	healthy := true
+	components := make(map[string]interface{})
+	for name, check := range h.healthChecks {
+		state, ok := check()
+		components[name] = state
+		healthy = healthy && ok
+	}
+
+	response.WithHealth(w, healthy, components)
 }
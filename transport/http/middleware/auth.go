@@ -2,69 +2,183 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/evermos/boilerplate-go/configs"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/shared"
+	"github.com/evermos/boilerplate-go/shared/audit"
+	"github.com/evermos/boilerplate-go/shared/logger"
 	"github.com/evermos/boilerplate-go/shared/oauth"
 	"github.com/evermos/boilerplate-go/transport/http/response"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog"
 )
 
+// APIKeyAuthenticator authenticates API keys, e.g. user.APIKeyService.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (claims *shared.Claims, err error)
+	ParsePrefix(key string) (prefix string, ok bool)
+}
+
+// SessionVerifier verifies that the login session of a token is still active,
+// e.g. user.SessionService.
+type SessionVerifier interface {
+	Verify(ctx context.Context, id uuid.UUID, userID uuid.UUID) (err error)
+}
+
 type Authentication struct {
-	db     *infras.MySQLConn
-	config *configs.Config
-	jwt    shared.JWTService
+	db       *infras.MySQLConn
+	config   *configs.Config
+	jwt      shared.JWTService
+	apiKeys  APIKeyAuthenticator
+	sessions SessionVerifier
+	audit    audit.Recorder
+	denials  *denials
 }
 
 const (
 	HeaderAuthorization = "Authorization"
+	ContextKeyClaims    = "claims"
 )
 
-func ProvideAuthentication(db *infras.MySQLConn, config *configs.Config) *Authentication {
+func ProvideAuthentication(db *infras.MySQLConn, config *configs.Config, apiKeys APIKeyAuthenticator, sessions SessionVerifier, auditRecorder audit.Recorder) *Authentication {
 	return &Authentication{
 		db:     db,
 		config: config,
 		jwt: *shared.ProvideJWTService(
 			config.App.Secret,
 		),
+		apiKeys:  apiKeys,
+		sessions: sessions,
+		audit:    auditRecorder,
+		denials:  newDenials(),
 	}
 }
 
+// ClaimsFromContext returns the claims stored by ClientCredentialWithJWT or APIKey.
+func ClaimsFromContext(ctx context.Context) (claims *shared.Claims, ok bool) {
+	claims, ok = ctx.Value(ContextKeyClaims).(*shared.Claims)
+	return
+}
+
+// withClaims stores claims in the context of a request, and adds their user to
+// the request's logger.
+func withClaims(r *http.Request, claims *shared.Claims) *http.Request {
+	ctx := context.WithValue(r.Context(), ContextKeyClaims, claims)
+	logger.UpdateContext(ctx, func(c zerolog.Context) zerolog.Context {
+		return c.Str("userId", claims.UserID.String())
+	})
+	return r.WithContext(ctx)
+}
+
 func (a *Authentication) ClientCredentialWithJWT(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized")
+			a.deny(w, r, http.StatusUnauthorized, "Unauthorized", "", "missing bearer token")
 			return
 		}
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
 		claims, err := a.jwt.ValidateJWT(tokenString)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, "Unauthorized")
+			a.deny(w, r, http.StatusUnauthorized, "Unauthorized", "", err.Error())
+			return
+		}
+
+		// tokens are bound to a login session, which may have been revoked
+		if err := a.sessions.Verify(r.Context(), claims.SessionID, claims.UserID); err != nil {
+			a.deny(w, r, http.StatusUnauthorized, "Unauthorized", claims.UserID.String(), err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, withClaims(r, claims))
+	})
+}
+
+// APIKey authenticates requests carrying an "Authorization: ApiKey <key>"
+// header and stores the key owner's claims in the request context.
+func (a *Authentication) APIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get(HeaderAuthorization)
+		if authHeader == "" || !strings.HasPrefix(authHeader, "ApiKey ") {
+			a.deny(w, r, http.StatusUnauthorized, "Unauthorized", "", "missing api key")
+			return
+		}
+		key := strings.TrimPrefix(authHeader, "ApiKey ")
+
+		claims, err := a.apiKeys.Authenticate(r.Context(), key)
+		if err != nil {
+			prefix, _ := a.apiKeys.ParsePrefix(key)
+			a.deny(w, r, http.StatusUnauthorized, "Unauthorized", prefix, err.Error())
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), "claims", claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withClaims(r, claims))
+	})
+}
+
+// ClientCredentialWithJWTOrAPIKey accepts either a bearer JWT or an API key.
+func (a *Authentication) ClientCredentialWithJWTOrAPIKey(next http.Handler) http.Handler {
+	withJWT := a.ClientCredentialWithJWT(next)
+	withAPIKey := a.APIKey(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get(HeaderAuthorization), "ApiKey ") {
+			withAPIKey.ServeHTTP(w, r)
+			return
+		}
+		withJWT.ServeHTTP(w, r)
 	})
 }
 
+// RequireScope rejects requests whose claims were not granted a scope.
+func (a *Authentication) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !claims.HasScope(scope) {
+				a.deny(w, r, http.StatusForbidden, "Forbidden", subjectFromClaims(claims), "missing scope "+scope)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole rejects requests whose claims do not carry a role.
+func (a *Authentication) RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || claims.Role != role {
+				a.deny(w, r, http.StatusForbidden, "Forbidden", subjectFromClaims(claims), "missing role "+role)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (a *Authentication) ClientCredential(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		accessToken := r.Header.Get(HeaderAuthorization)
-		token := oauth.New(a.db.Read, oauth.Config{})
+		token := oauth.New(a.db.Read, oauth.Config{Audit: a.audit})
 
 		parseToken, err := token.ParseWithAccessToken(accessToken)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, err.Error(), "", err.Error())
 			return
 		}
 
 		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, oauth.ErrorInvalidToken, parseToken.ClientID, "token expired")
 			return
 		}
 
@@ -79,15 +193,15 @@ func (a *Authentication) ClientCredentialWithQueryParameter(next http.Handler) h
 		tokenType := params.Get("token_type")
 		accessToken := tokenType + " " + token
 
-		auth := oauth.New(a.db.Read, oauth.Config{})
+		auth := oauth.New(a.db.Read, oauth.Config{Audit: a.audit})
 		parseToken, err := auth.ParseWithAccessToken(accessToken)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, err.Error(), "", err.Error())
 			return
 		}
 
 		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, oauth.ErrorInvalidToken, parseToken.ClientID, "token expired")
 			return
 		}
 
@@ -98,24 +212,64 @@ func (a *Authentication) ClientCredentialWithQueryParameter(next http.Handler) h
 func (a *Authentication) Password(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		accessToken := r.Header.Get(HeaderAuthorization)
-		token := oauth.New(a.db.Read, oauth.Config{})
+		token := oauth.New(a.db.Read, oauth.Config{Audit: a.audit})
 
 		parseToken, err := token.ParseWithAccessToken(accessToken)
 		if err != nil {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, err.Error(), "", err.Error())
 			return
 		}
 
 		if !parseToken.VerifyExpireIn() {
-			response.WithMessage(w, http.StatusUnauthorized, err.Error())
+			a.deny(w, r, http.StatusUnauthorized, oauth.ErrorInvalidToken, parseToken.ClientID, "token expired")
 			return
 		}
 
 		if !parseToken.VerifyUserLoggedIn() {
-			response.WithMessage(w, http.StatusUnauthorized, oauth.ErrorInvalidPassword)
+			a.deny(w, r, http.StatusUnauthorized, oauth.ErrorInvalidPassword, parseToken.ClientID, "token not issued to a user")
 			return
 		}
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// deny rejects a request and records it in the audit log. Denials of the same
+// client are recorded once every denialInterval at most; the next recorded one
+// carries the number suppressed in between.
+func (a *Authentication) deny(w http.ResponseWriter, r *http.Request, code int, message string, subject string, reason string) {
+	ip := ClientIP(r)
+	if record, suppressed := a.denials.allow(ip, time.Now()); record {
+		a.audit.Record(r.Context(), audit.Event{
+			Type:      audit.EventTypeAccessDenied,
+			Subject:   subject,
+			IPAddress: ip,
+			UserAgent: r.UserAgent(),
+			Outcome:   audit.OutcomeFailure,
+			Metadata: audit.Metadata{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"reason":     reason,
+				"suppressed": suppressed,
+			},
+		})
+	}
+
+	response.WithMessage(w, code, message)
+}
+
+// ClientIP returns the IP address a request was sent from.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func subjectFromClaims(claims *shared.Claims) string {
+	if claims == nil {
+		return ""
+	}
+	return claims.UserID.String()
+}
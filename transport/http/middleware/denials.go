@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// denialInterval is how often denials from the same client are recorded
+	// in the audit log at most.
+	denialInterval = 10 * time.Second
+	// maxTrackedClients bounds the memory used to throttle denials.
+	maxTrackedClients = 10000
+)
+
+// denials throttles the auditing of denied requests, so a client hammering an
+// endpoint with bad credentials cannot flood the audit log. Denials suppressed
+// since the last recorded one are counted and reported with the next one.
+type denials struct {
+	mu      sync.Mutex
+	clients map[string]*deniedClient
+}
+
+type deniedClient struct {
+	recordedAt time.Time
+	suppressed int
+}
+
+func newDenials() *denials {
+	return &denials{clients: make(map[string]*deniedClient)}
+}
+
+// allow returns whether a denial of a request from ip is to be recorded, and
+// how many denials of it were suppressed before.
+func (d *denials) allow(ip string, now time.Time) (record bool, suppressed int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	client, ok := d.clients[ip]
+	if ok && now.Sub(client.recordedAt) < denialInterval {
+		client.suppressed++
+		return false, 0
+	}
+
+	if ok {
+		suppressed = client.suppressed
+	} else if len(d.clients) >= maxTrackedClients {
+		d.prune(now)
+	}
+
+	d.clients[ip] = &deniedClient{recordedAt: now}
+	return true, suppressed
+}
+
+// prune forgets clients not denied recently, or every client if all were.
+func (d *denials) prune(now time.Time) {
+	for ip, client := range d.clients {
+		if now.Sub(client.recordedAt) >= denialInterval {
+			delete(d.clients, ip)
+		}
+	}
+
+	if len(d.clients) >= maxTrackedClients {
+		d.clients = make(map[string]*deniedClient)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDenials(t *testing.T) {
+	d := newDenials()
+	now := time.Now()
+
+	record, suppressed := d.allow("10.0.0.1", now)
+	assert.True(t, record)
+	assert.Equal(t, 0, suppressed)
+
+	for i := 0; i < 3; i++ {
+		record, _ = d.allow("10.0.0.1", now.Add(time.Second))
+		assert.False(t, record)
+	}
+
+	// other clients are not throttled by it
+	record, _ = d.allow("10.0.0.2", now.Add(time.Second))
+	assert.True(t, record)
+
+	record, suppressed = d.allow("10.0.0.1", now.Add(denialInterval))
+	assert.True(t, record)
+	assert.Equal(t, 3, suppressed)
+}
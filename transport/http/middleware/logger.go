@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	chiMiddleware "github.com/go-chi/chi/middleware"
+)
+
+// Logger logs every request once it is served, with the logger of the
+// request, so the entry carries its ID, route and user. Use it after
+// RequestID.
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		logger.FromContext(r.Context()).
+			Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", status).
+			Int("bytes", ww.BytesWritten()).
+			Dur("duration", time.Since(start)).
+			Str("remoteAddr", r.RemoteAddr).
+			Msg("Served request.")
+	})
+}
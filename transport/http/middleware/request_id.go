@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/go-chi/chi"
+	chiMiddleware "github.com/go-chi/chi/middleware"
+	"github.com/gofrs/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// HeaderRequestID carries the ID correlating the logs of a request, e.g.
+// across services.
+const HeaderRequestID = "X-Request-ID"
+
+// validRequestID keeps IDs sent by callers short and printable, as they end
+// up in logs.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID takes the ID of a request from its X-Request-ID header, or
+// generates one, and returns it in the response. The request gets its own
+// logger carrying the ID and the route pattern; use logger.FromContext to log
+// with it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderRequestID)
+		if !validRequestID.MatchString(id) {
+			generated, _ := uuid.NewV4()
+			id = generated.String()
+		}
+		w.Header().Set(HeaderRequestID, id)
+
+		requestLogger := log.With().Str("requestId", id).Logger()
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			requestLogger = requestLogger.Hook(routeHook{rctx})
+		}
+
+		// chi's own middleware reads the ID from its key
+		ctx := context.WithValue(r.Context(), chiMiddleware.RequestIDKey, id)
+		ctx = logger.NewContext(ctx, requestLogger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the ID of the request being served.
+func RequestIDFromContext(ctx context.Context) string {
+	return chiMiddleware.GetReqID(ctx)
+}
+
+// routeHook adds the route pattern to every event, as the route is only
+// known once the request is routed.
+type routeHook struct {
+	rctx *chi.Context
+}
+
+func (h routeHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
+	if pattern := h.rctx.RoutePattern(); pattern != "" {
+		e.Str("route", pattern)
+	}
+}
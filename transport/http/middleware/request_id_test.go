@@ -0,0 +1,60 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/shared/logger"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	logs := &bytes.Buffer{}
+	global := log.Logger
+	log.Logger = zerolog.New(logs)
+	defer func() { log.Logger = global }()
+
+	mux := chi.NewRouter()
+	mux.Use(middleware.RequestID)
+	mux.Get("/v1/foo/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Info().Msg("handled")
+	})
+
+	t.Run("Accepts the caller's ID", func(t *testing.T) {
+		logs.Reset()
+		request := httptest.NewRequest(http.MethodGet, "/v1/foo/1", nil)
+		request.Header.Set(middleware.HeaderRequestID, "abc-123")
+		recorder := httptest.NewRecorder()
+
+		mux.ServeHTTP(recorder, request)
+
+		assert.Equal(t, "abc-123", recorder.Header().Get(middleware.HeaderRequestID))
+
+		entry := map[string]string{}
+		assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+		assert.Equal(t, "abc-123", entry["requestId"])
+		assert.Equal(t, "/v1/foo/{id}", entry["route"])
+	})
+
+	t.Run("Generates an ID if missing or invalid", func(t *testing.T) {
+		for _, id := range []string{"", "not a valid id", strings.Repeat("a", 129)} {
+			request := httptest.NewRequest(http.MethodGet, "/v1/foo/1", nil)
+			request.Header.Set(middleware.HeaderRequestID, id)
+			recorder := httptest.NewRecorder()
+
+			mux.ServeHTTP(recorder, request)
+
+			generated := recorder.Header().Get(middleware.HeaderRequestID)
+			assert.NotEmpty(t, generated)
+			assert.NotEqual(t, id, generated)
+		}
+	})
+}
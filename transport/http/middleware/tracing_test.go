@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/evermos/boilerplate-go/configs"
+	"github.com/evermos/boilerplate-go/shared/tracing"
+	"github.com/evermos/boilerplate-go/transport/http/middleware"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+)
+
+func TestTracing(t *testing.T) {
+	config := &configs.Config{}
+	_, err := tracing.Init(config)
+	assert.NoError(t, err)
+
+	spans := &bytes.Buffer{}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(spans))
+	assert.NoError(t, err)
+	provider := tracing.NewProvider(config, exporter)
+	otel.SetTracerProvider(provider)
+
+	mux := chi.NewRouter()
+	mux.Use(middleware.Tracing)
+	mux.Get("/v1/foo/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// the span of the request is passed on to the handler
+		attributes := tracing.Inject(r.Context(), nil)
+		assert.Contains(t, attributes["traceparent"], "4bf92f3577b34da6a3ce929d0e0e4736")
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/v1/foo/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mux.ServeHTTP(httptest.NewRecorder(), request)
+
+	// shutting down exports every span ended so far
+	assert.NoError(t, provider.Shutdown(context.Background()))
+	assert.Contains(t, spans.String(), `"Name":"GET /v1/foo/{id}"`)
+	assert.Contains(t, spans.String(), `"TraceID":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+}
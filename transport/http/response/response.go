@@ -47,6 +47,23 @@ func WithUnhealthy(w http.ResponseWriter) {
 	WithMessage(w, http.StatusServiceUnavailable, "SERVER UNHEALTHY")
 }
 
+// WithHealth sends a health check response, including the state of each
+// component checked
+func WithHealth(w http.ResponseWriter, healthy bool, components map[string]interface{}) {
+	code, message := http.StatusOK, "OK"
+	if !healthy {
+		code, message = http.StatusServiceUnavailable, "SERVER UNHEALTHY"
+	}
+
+	payload := Base{Message: &message}
+	if len(components) > 0 {
+		var data interface{} = components
+		payload.Data = &data
+	}
+
+	respond(w, code, payload)
+}
+
 func respond(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
@@ -7,6 +7,8 @@ import (
 
 // DomainHandlers is a struct that contains all domain-specific handlers.
 type DomainHandlers struct {
+	AuditHandler     handlers.AuditHandler
+	EventHandler     handlers.EventHandler
 	FooBarBazHandler handlers.FooBarBazHandler
 	UserHandler      handlers.UserHandler
 }
@@ -28,5 +30,8 @@ func (r *Router) SetupRoutes(mux *chi.Mux) {
 	mux.Route("/v1", func(rc chi.Router) {
 		r.DomainHandlers.FooBarBazHandler.Router(rc)
 		r.DomainHandlers.UserHandler.Router(rc)
+		r.DomainHandlers.AuditHandler.Router(rc)
 	})
+
+	r.DomainHandlers.EventHandler.Router(mux)
 }
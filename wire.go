@@ -5,13 +5,17 @@ package main
 
 import (
 	"github.com/evermos/boilerplate-go/configs"
-	// "github.com/evermos/boilerplate-go/event"
-	// fooBarBazEvent "github.com/evermos/boilerplate-go/event/domain/foobarbaz"
+	"github.com/evermos/boilerplate-go/event"
+	"github.com/evermos/boilerplate-go/event/consumer"
+	fooBarBazEvent "github.com/evermos/boilerplate-go/event/domain/foobarbaz"
+	"github.com/evermos/boilerplate-go/event/outbox"
 	"github.com/evermos/boilerplate-go/event/producer"
 	"github.com/evermos/boilerplate-go/infras"
 	"github.com/evermos/boilerplate-go/internal/domain/foobarbaz"
 	"github.com/evermos/boilerplate-go/internal/domain/user"
 	"github.com/evermos/boilerplate-go/internal/handlers"
+	"github.com/evermos/boilerplate-go/internal/janitor"
+	"github.com/evermos/boilerplate-go/shared/audit"
 	"github.com/evermos/boilerplate-go/transport/http"
 	"github.com/evermos/boilerplate-go/transport/http/middleware"
 	"github.com/evermos/boilerplate-go/transport/http/router"
@@ -36,9 +40,12 @@ var domainFooBarBaz = wire.NewSet(
 	// FooRepository interface and implementation
 	foobarbaz.ProvideFooRepositoryMySQL,
 	wire.Bind(new(foobarbaz.FooRepository), new(*foobarbaz.FooRepositoryMySQL)),
-	// Producer interface and implementation
-	producer.NewSNSProducer,
-	wire.Bind(new(producer.Producer), new(*producer.SNSProducer)),
+)
+
+// Wiring for event producers.
+var producers = wire.NewSet(
+	// Producer selected by the event driver
+	producer.ProvideProducer,
 )
 
 // Wiring for domain User.
@@ -47,12 +54,31 @@ var domainUser = wire.NewSet(
 	wire.Bind(new(user.UserService), new(*user.UserServiceImpl)),
 	user.ProvideUserRepositoryMySQL,
 	wire.Bind(new(user.UserRepository), new(*user.UserRepositoryMySQL)),
+	user.ProvideAPIKeyServiceImpl,
+	wire.Bind(new(user.APIKeyService), new(*user.APIKeyServiceImpl)),
+	user.ProvideAPIKeyRepositoryMySQL,
+	wire.Bind(new(user.APIKeyRepository), new(*user.APIKeyRepositoryMySQL)),
+	user.ProvideSessionServiceImpl,
+	wire.Bind(new(user.SessionService), new(*user.SessionServiceImpl)),
+	user.ProvideSessionRepositoryMySQL,
+	wire.Bind(new(user.SessionRepository), new(*user.SessionRepositoryMySQL)),
+	// what the auth middleware needs of the domain
+	wire.Bind(new(middleware.APIKeyAuthenticator), new(*user.APIKeyServiceImpl)),
+	wire.Bind(new(middleware.SessionVerifier), new(*user.SessionServiceImpl)),
+)
+
+// Wiring for the security audit log.
+var auditLog = wire.NewSet(
+	audit.ProvideLog,
+	wire.Bind(new(audit.Recorder), new(*audit.Log)),
 )
 
 // Wiring for all domains.
 var domains = wire.NewSet(
+	producers,
 	domainFooBarBaz,
 	domainUser,
+	auditLog,
 )
 
 var authMiddleware = wire.NewSet(
@@ -61,47 +87,91 @@ var authMiddleware = wire.NewSet(
 
 // Wiring for HTTP routing.
 var routing = wire.NewSet(
-	wire.Struct(new(router.DomainHandlers), "FooBarBazHandler", "UserHandler"),
+	wire.Struct(new(router.DomainHandlers), "AuditHandler", "EventHandler", "FooBarBazHandler", "UserHandler"),
+	handlers.ProvideAuditHandler,
+	handlers.ProvideEventHandler,
 	handlers.ProvideFooBarBazHandler,
 	handlers.ProvideUserHandler,
 	router.ProvideRouter,
 )
 
 // Wiring for all domains event consumer.
-// var evco = wire.NewSet(
-// 	wire.Struct(new(event.Consumers), "FooBarBaz"),
-// 	fooBarBazEvent.ProvideConsumerImpl,
-// )
+var evco = wire.NewSet(
+	wire.Struct(new(event.Consumers), "FooBarBaz"),
+	fooBarBazEvent.ProvideConsumerImpl,
+	consumer.ProvideLedger,
+	consumer.ProvideVerifier,
+)
 
-// Wiring for everything.
-func InitializeService() *http.HTTP {
+// Wiring for the database connections, shared by everything else.
+func InitializeMySQLConn() *infras.MySQLConn {
 	wire.Build(
 		// configurations
 		configurations,
 		// persistences
-		persistences,
+		persistences)
+	return &infras.MySQLConn{}
+}
+
+// Wiring for everything.
+func InitializeService(db *infras.MySQLConn) *http.HTTP {
+	wire.Build(
+		// configurations
+		configurations,
 		// middleware
 		authMiddleware,
 		// domains
 		domains,
 		// routing
 		routing,
+		// event consumers, for pushed events
+		evco,
 		// selected transport layer
 		http.ProvideHTTP)
 	return &http.HTTP{}
 }
 
+// Wiring for the health server of workers.
+func InitializeHealth(db *infras.MySQLConn) *http.HTTP {
+	wire.Build(
+		// configurations
+		configurations,
+		// health check only transport layer
+		http.ProvideHealthHTTP)
+	return &http.HTTP{}
+}
+
+// Wiring for the expired token janitor.
+func InitializeJanitor(db *infras.MySQLConn) *janitor.Janitor {
+	wire.Build(
+		// configurations
+		configurations,
+		// janitor
+		janitor.ProvideJanitor)
+	return &janitor.Janitor{}
+}
+
+// Wiring for the outbox relay.
+func InitializeOutboxRelay(db *infras.MySQLConn) *outbox.Relay {
+	wire.Build(
+		// configurations
+		configurations,
+		// event producers
+		producers,
+		// outbox relay
+		outbox.ProvideRelay)
+	return &outbox.Relay{}
+}
+
 // Wiring the event needs.
-// func InitializeEvent() event.Consumers {
-// 	wire.Build(
-// 		// configurations
-// 		configurations,
-// 		// persistences
-// 		persistences,
-// 		// domains
-// 		domains,
-// 		// event consumer
-// 		evco)
-
-// 	return event.Consumers{}
-// }
+func InitializeEvent(db *infras.MySQLConn) event.Consumers {
+	wire.Build(
+		// configurations
+		configurations,
+		// domains
+		domains,
+		// event consumer
+		evco)
+
+	return event.Consumers{}
+}